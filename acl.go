@@ -0,0 +1,78 @@
+package hsvault
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// capabilityOrder is the order of capabilities in generated policies
+var capabilityOrder = []string{"create", "read", "update", "delete", "list", "sudo"}
+
+// ACLPolicy returns an ACL policy granting requirements, it is written in namespace and paths of child
+// namespaces are prefixed by their relative path. Capabilities of the same path are merged
+func ACLPolicy(reqs []Requirement, namespace string) string {
+	type rule struct {
+		path         string
+		engines      []string
+		capabilities []string
+	}
+
+	var rules []*rule
+	byPath := map[string]*rule{}
+	for _, req := range reqs {
+		p := req.Path
+		if rel := strings.TrimPrefix(strings.TrimPrefix(strings.Trim(req.Namespace, "/"), strings.Trim(namespace, "/")), "/"); rel != "" {
+			p = rel + "/" + p
+		}
+
+		r, ok := byPath[p]
+		if !ok {
+			r = &rule{path: p}
+			byPath[p] = r
+			rules = append(rules, r)
+		}
+		engine := fmt.Sprintf("%v (%v)", path.Join(req.Namespace, req.Engine), req.Type)
+		if req.Engine != "" && !contains(r.engines, engine) {
+			r.engines = append(r.engines, engine)
+		}
+		for _, capability := range req.Capabilities {
+			if !contains(r.capabilities, capability) {
+				r.capabilities = append(r.capabilities, capability)
+			}
+		}
+	}
+
+	// paths of an engine follow each other, they are commented once
+	var b strings.Builder
+	comment := ""
+	for i, r := range rules {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if c := strings.Join(r.engines, ", "); c != "" && c != comment {
+			fmt.Fprintf(&b, "# %v\n", c)
+			comment = c
+		}
+
+		sort.Slice(r.capabilities, func(i, j int) bool {
+			return capabilityRank(r.capabilities[i]) < capabilityRank(r.capabilities[j])
+		})
+		quoted := make([]string, len(r.capabilities))
+		for i, capability := range r.capabilities {
+			quoted[i] = fmt.Sprintf("%q", capability)
+		}
+		fmt.Fprintf(&b, "path %q {\n  capabilities = [%v]\n}\n", r.path, strings.Join(quoted, ", "))
+	}
+	return b.String()
+}
+
+func capabilityRank(capability string) int {
+	for i, c := range capabilityOrder {
+		if c == capability {
+			return i
+		}
+	}
+	return len(capabilityOrder)
+}
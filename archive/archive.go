@@ -0,0 +1,239 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// Extension of compressed backups
+	Extension = ".tar.gz"
+	// EncryptedExtension of compressed and encrypted backups
+	EncryptedExtension = ".tar.gz.enc"
+
+	// magic prefixes encrypted files, followed by the GCM nonce and the sealed archive
+	magic = "HSV1"
+)
+
+// IsArchive reports whether f is a backup written by Pack
+func IsArchive(f string) bool {
+	return strings.HasSuffix(f, Extension) || strings.HasSuffix(f, EncryptedExtension)
+}
+
+// Pack compresses dir into dir.tar.gz, encrypted into dir.tar.gz.enc when key is set, and removes dir
+func Pack(dir string, key []byte) (string, error) {
+	var buf bytes.Buffer
+	if err := compress(dir, &buf); err != nil {
+		return "", err
+	}
+
+	out := strings.TrimSuffix(dir, "/") + Extension
+	content := buf.Bytes()
+	if key != nil {
+		sealed, err := encrypt(key, content)
+		if err != nil {
+			return "", err
+		}
+		out, content = strings.TrimSuffix(dir, "/")+EncryptedExtension, sealed
+	}
+
+	if err := os.WriteFile(out, content, 0600); err != nil {
+		return "", err
+	}
+	return out, os.RemoveAll(dir)
+}
+
+// Unpack extracts an archive written by Pack into dest
+func Unpack(f string, key []byte, dest string) error {
+	content, err := os.ReadFile(f)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(f, EncryptedExtension) {
+		if key == nil {
+			return fmt.Errorf("'%v' is encrypted, an encryption key is required", f)
+		}
+		if content, err = decrypt(key, content); err != nil {
+			return fmt.Errorf("decrypt '%v': %w", f, err)
+		}
+	}
+
+	return extract(bytes.NewReader(content), dest)
+}
+
+// LoadKey reads a 32 bytes key, raw or base64 encoded, from a file or a base64 encoded key
+// from an environment variable, it returns nil when neither is set
+func LoadKey(keyFile, keyEnv string) ([]byte, error) {
+	var encoded []byte
+	switch {
+	case keyFile != "":
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(content) == 32 {
+			return content, nil
+		}
+		encoded = bytes.TrimSpace(content)
+	case keyEnv != "":
+		value, ok := os.LookupEnv(keyEnv)
+		if !ok {
+			return nil, fmt.Errorf("environment variable '%v' is not set", keyEnv)
+		}
+		encoded = []byte(strings.TrimSpace(value))
+	default:
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is neither 32 bytes nor base64 encoded: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key is %d bytes, expected 32", len(key))
+	}
+	return key, nil
+}
+
+func compress(dir string, w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func extract(r io.Reader, dest string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gr)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// entries must stay inside dest
+		target := filepath.Join(dest, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("archive entry '%v' is outside of the destination", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode)&0777)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				_ = f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(magic), nonce...)
+	return gcm.Seal(out, nonce, plaintext, []byte(magic)), nil
+}
+
+func decrypt(key, content []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(content, []byte(magic)) || len(content) < len(magic)+gcm.NonceSize() {
+		return nil, fmt.Errorf("not an encrypted backup")
+	}
+	content = content[len(magic):]
+	return gcm.Open(nil, content[:gcm.NonceSize()], content[gcm.NonceSize():], []byte(magic))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
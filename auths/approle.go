@@ -0,0 +1,126 @@
+package auths
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/zduymz/hs-vault/backends"
+	"go.uber.org/zap"
+	"os"
+	"path"
+)
+
+type AppRole struct {
+	*backends.Object
+}
+
+// appRolePaths look up secret-id accessors when they are backed up
+var appRolePaths = backends.Paths{
+	Backup:  backends.Join(backends.Reads("role/*"), backends.Writes("role/+/secret-id-accessor/lookup")),
+	Restore: backends.Writes("role/*"),
+}
+
+func (s *AppRole) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	l.Debug("Start backup roles")
+	if err := s.VaultBackupRoles(ctx, "role"); err != nil {
+		return err
+	}
+
+	roles, err := s.VaultWalk(ctx, s.Engine.Path, "role")
+	if err != nil {
+		return err
+	}
+
+	for _, p := range roles {
+		name := path.Base(p)
+
+		vp := path.Join(s.Engine.Path, "role", name, "role-id")
+		l.Debug("Read role id", zap.String("path", vp))
+		data, err := s.Vault.Read(ctx, vp)
+		if err != nil {
+			return err
+		}
+
+		if err := s.WriteVaultResponse(ctx, path.Join("role-id", name), data.Data); err != nil {
+			return err
+		}
+
+		if s.Options.BackupSecretIDs {
+			if err := s.backupSecretIDs(ctx, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// backupSecretIDs keeps secret-id accessors metadata for reference, secret-ids can not be read back
+func (s *AppRole) backupSecretIDs(ctx context.Context, role string) error {
+	l := s.L.With(zap.String("method", "backupSecretIDs"))
+
+	accessors, err := s.VaultWalk(ctx, path.Join(s.Engine.Path, "role", role), "secret-id")
+	if err != nil {
+		return err
+	}
+
+	for _, p := range accessors {
+		accessor := path.Base(p)
+		vp := path.Join(s.Engine.Path, "role", role, "secret-id-accessor/lookup")
+		l.Debug("Lookup secret id accessor", zap.String("path", vp), zap.String("role", role))
+		data, err := s.Vault.Write(ctx, vp, map[string]interface{}{
+			"secret_id_accessor": accessor,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := s.WriteVaultResponse(ctx, path.Join("secret-id", role, accessor), data.Data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *AppRole) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	l.Debug("Start restore roles")
+	if err := s.VaultRestoreRoles(ctx, "role"); err != nil {
+		return err
+	}
+
+	l.Debug("Start restore role ids")
+	paths, err := s.LocalWalk(ctx, s.Options.RestorePath, "role-id")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, p := range paths {
+		data, err := s.ReadFileAndB64Decode(ctx, p)
+		if err != nil {
+			return err
+		}
+
+		payload := map[string]interface{}{}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+
+		vp := path.Join(s.Engine.Path, "role", path.Base(p), "role-id")
+		l.Debug("Write role id to vault", zap.String("path", vp))
+		if err := s.VaultWrite(ctx, vp, map[string]interface{}{
+			"role_id": payload["role_id"],
+		}); err != nil {
+			return err
+		}
+	}
+
+	if _, err := os.Stat(path.Join(s.Options.RestorePath, "secret-id")); err == nil {
+		l.Info("Secret id accessors metadata is kept for reference only, secret ids must be issued again")
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package auths
+
+import (
+	"context"
+	"github.com/zduymz/hs-vault/backends"
+	"go.uber.org/zap"
+	"path"
+)
+
+// JWT handles both jwt and oidc auth methods, they are the same plugin
+type JWT struct {
+	*backends.Object
+}
+
+var jwtPaths = backends.Paths{
+	Backup:  backends.Join(backends.RawReads("config"), backends.Reads("config", "role/*")),
+	Restore: backends.Writes("config", "role/*"),
+}
+
+func (s *JWT) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	l.Debug("Start backup config")
+	keyPrefix := path.Join("auth", s.Engine.UUID)
+	if err := s.VaultBackupSingleKey(ctx, keyPrefix, "config", "oidc_client_secret"); err != nil {
+		return err
+	}
+
+	l.Debug("Start backup roles")
+	return s.VaultBackupRoles(ctx, "role")
+}
+
+func (s *JWT) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	l.Debug("Start restore config")
+	if err := s.VaultRestoreSingleKey(ctx, "config"); err != nil {
+		return err
+	}
+
+	l.Debug("Start restore roles")
+	return s.VaultRestoreRoles(ctx, "role")
+}
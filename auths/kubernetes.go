@@ -0,0 +1,42 @@
+package auths
+
+import (
+	"context"
+	"github.com/zduymz/hs-vault/backends"
+	"go.uber.org/zap"
+	"path"
+)
+
+type Kubernetes struct {
+	*backends.Object
+}
+
+var kubernetesPaths = backends.Paths{
+	Backup:  backends.Join(backends.RawReads("config"), backends.Reads("config", "role/*")),
+	Restore: backends.Writes("config", "role/*"),
+}
+
+func (s *Kubernetes) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	l.Debug("Start backup config")
+	keyPrefix := path.Join("auth", s.Engine.UUID)
+	if err := s.VaultBackupSingleKey(ctx, keyPrefix, "config", "kubernetes_ca_cert", "token_reviewer_jwt"); err != nil {
+		return err
+	}
+
+	l.Debug("Start backup roles")
+	return s.VaultBackupRoles(ctx, "role")
+}
+
+func (s *Kubernetes) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	l.Debug("Start restore config")
+	if err := s.VaultRestoreSingleKey(ctx, "config"); err != nil {
+		return err
+	}
+
+	l.Debug("Start restore roles")
+	return s.VaultRestoreRoles(ctx, "role")
+}
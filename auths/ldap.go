@@ -0,0 +1,52 @@
+package auths
+
+import (
+	"context"
+	"github.com/zduymz/hs-vault/backends"
+	"go.uber.org/zap"
+	"path"
+)
+
+type LDAP struct {
+	*backends.Object
+}
+
+var ldapPaths = backends.Paths{
+	Backup:  backends.Join(backends.RawReads("config"), backends.Reads("config", "groups/*", "users/*")),
+	Restore: backends.Writes("config", "groups/*", "users/*"),
+}
+
+func (s *LDAP) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	l.Debug("Start backup config")
+	keyPrefix := path.Join("auth", s.Engine.UUID)
+	if err := s.VaultBackupSingleKey(ctx, keyPrefix, "config", "bindpass", "client_tls_key"); err != nil {
+		return err
+	}
+
+	l.Debug("Start backup groups")
+	if err := s.VaultBackupRoles(ctx, "groups"); err != nil {
+		return err
+	}
+
+	l.Debug("Start backup users")
+	return s.VaultBackupRoles(ctx, "users")
+}
+
+func (s *LDAP) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	l.Debug("Start restore config")
+	if err := s.VaultRestoreSingleKey(ctx, "config"); err != nil {
+		return err
+	}
+
+	l.Debug("Start restore groups")
+	if err := s.VaultRestoreRoles(ctx, "groups"); err != nil {
+		return err
+	}
+
+	l.Debug("Start restore users")
+	return s.VaultRestoreRoles(ctx, "users")
+}
@@ -0,0 +1,26 @@
+package auths
+
+import (
+	"context"
+	"github.com/zduymz/hs-vault/backends"
+	"go.uber.org/zap"
+)
+
+type Token struct {
+	*backends.Object
+}
+
+var tokenPaths = backends.Paths{
+	Backup:  backends.Reads("roles/*"),
+	Restore: backends.Writes("roles/*"),
+}
+
+func (s *Token) Backup(ctx context.Context) error {
+	s.L.With(zap.String("method", "Backup")).Debug("Start backup token roles")
+	return s.VaultBackupRoles(ctx, "roles")
+}
+
+func (s *Token) Restore(ctx context.Context) error {
+	s.L.With(zap.String("method", "Restore")).Debug("Start restore token roles")
+	return s.VaultRestoreRoles(ctx, "roles")
+}
@@ -0,0 +1,56 @@
+package auths
+
+import (
+	"github.com/hashicorp/vault-client-go"
+	"github.com/zduymz/hs-vault/backends"
+)
+
+type AuthType string
+
+const (
+	AppRoleAuth    AuthType = "approle"
+	JWTAuth        AuthType = "jwt"
+	KubernetesAuth AuthType = "kubernetes"
+	LDAPAuth       AuthType = "ldap"
+	OIDCAuth       AuthType = "oidc"
+	TokenAuth      AuthType = "token"
+	UserpassAuth   AuthType = "userpass"
+)
+
+// methods are the auth methods implementations, they are keyed by auth method type
+var methods = backends.NewRegistry()
+
+// Register adds an auth method implementation, it panics when the type is already registered
+func Register(at AuthType, factory backends.Factory, capabilities backends.Capabilities) {
+	methods.Register(backends.EngineType(at), factory, capabilities)
+}
+
+// Lookup returns the auth method implementation of the type
+func Lookup(at AuthType) (backends.Registration, bool) {
+	return methods.Lookup(backends.EngineType(at))
+}
+
+// Supported reports whether the auth method type is registered
+func Supported(at AuthType) bool {
+	_, ok := Lookup(at)
+	return ok
+}
+
+// NewAuthMethod returns the engine for an auth method mounted at e.Path, eg: auth/approle
+func NewAuthMethod(v *vault.Client, e *backends.SecretEngine, options *backends.Options, at AuthType) (backends.Engine, error) {
+	return methods.New(v, e, options, backends.EngineType(at))
+}
+
+func init() {
+	api := func(paths backends.Paths) backends.Capabilities {
+		return backends.Capabilities{Verify: true, Diff: true, Paths: paths}
+	}
+
+	Register(AppRoleAuth, func(o *backends.Object) backends.Engine { return &AppRole{o} }, api(appRolePaths))
+	Register(JWTAuth, func(o *backends.Object) backends.Engine { return &JWT{o} }, api(jwtPaths))
+	Register(KubernetesAuth, func(o *backends.Object) backends.Engine { return &Kubernetes{o} }, api(kubernetesPaths))
+	Register(LDAPAuth, func(o *backends.Object) backends.Engine { return &LDAP{o} }, api(ldapPaths))
+	Register(OIDCAuth, func(o *backends.Object) backends.Engine { return &JWT{o} }, api(jwtPaths))
+	Register(TokenAuth, func(o *backends.Object) backends.Engine { return &Token{o} }, api(tokenPaths))
+	Register(UserpassAuth, func(o *backends.Object) backends.Engine { return &Userpass{o} }, api(userpassPaths))
+}
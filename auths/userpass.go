@@ -0,0 +1,76 @@
+package auths
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/zduymz/hs-vault/backends"
+	"go.uber.org/zap"
+	"os"
+	"path"
+)
+
+type Userpass struct {
+	*backends.Object
+}
+
+// userpassPaths read users through the API when password hashes can not be read from storage
+var userpassPaths = backends.Paths{
+	Backup:  backends.Join(backends.RawReads("user/*"), backends.Reads("users/*")),
+	Restore: backends.Join(backends.RawWrites("user/*"), backends.Reads("users/*"), backends.Writes("users/*")),
+}
+
+func (s *Userpass) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	// password hashes are only reachable from storage
+	if s.Options.RawAccessible {
+		l.Debug("Start backup users with password hashes")
+		keyPrefix := path.Join("auth", s.Engine.UUID)
+		return s.RawBackup(ctx, keyPrefix, "user")
+	}
+
+	l.Debug("Start backup users")
+	return s.VaultBackupRoles(ctx, "users")
+}
+
+func (s *Userpass) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	if _, err := os.Stat(path.Join(s.Options.RestorePath, "user")); err == nil {
+		l.Debug("Start restore users with password hashes")
+		keyPrefix := path.Join("auth", s.Engine.UUID)
+		return s.RawRestore(ctx, keyPrefix, "user")
+	}
+
+	l.Debug("Start restore users")
+	paths, err := s.LocalWalk(ctx, s.Options.RestorePath, "users")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, p := range paths {
+		// a user can not be created without password, only existing users are updated
+		vp := path.Join(s.Engine.Path, p)
+		if _, err := s.Vault.Read(ctx, vp); err != nil {
+			l.Warn("User does not exist and backup has no password hash, skip restore user", zap.String("path", vp))
+			continue
+		}
+
+		data, err := s.ReadFileAndB64Decode(ctx, p)
+		if err != nil {
+			return err
+		}
+
+		payload := map[string]interface{}{}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+
+		l.Debug("Write user to vault", zap.String("path", vp))
+		if err := s.VaultWrite(ctx, vp, payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package backends
+
+import (
+	"strings"
+)
+
+// Access is a path an engine uses with the Vault capabilities it needs. Path is relative to the mount,
+// or to the storage of the mount under sys/raw when Raw is set. Paths are ACL paths, * and + are globs
+type Access struct {
+	Path         string
+	Capabilities []string
+	Raw          bool
+}
+
+// Paths are the paths an engine uses during backup and restore
+type Paths struct {
+	Backup  []Access
+	Restore []Access
+}
+
+// Reads returns read access to paths, paths ending with * are listed too
+func Reads(paths ...string) []Access {
+	return access(paths, false, "read")
+}
+
+// Writes returns create and update access to paths
+func Writes(paths ...string) []Access {
+	return access(paths, false, "create", "update")
+}
+
+// RawReads returns read access to storage paths of the mount, paths ending with * are listed too.
+// Raw accesses need sudo
+func RawReads(paths ...string) []Access {
+	return access(paths, true, "read")
+}
+
+// RawWrites returns create and update access to storage paths of the mount
+func RawWrites(paths ...string) []Access {
+	return access(paths, true, "create", "update")
+}
+
+func access(paths []string, raw bool, capabilities ...string) []Access {
+	var accesses []Access
+	for _, p := range paths {
+		caps := append([]string{}, capabilities...)
+		if caps[0] == "read" && strings.HasSuffix(p, "*") {
+			caps = append(caps, "list")
+		}
+		// sys/raw is root protected
+		if raw {
+			caps = append(caps, "sudo")
+		}
+		accesses = append(accesses, Access{Path: p, Capabilities: caps, Raw: raw})
+	}
+	return accesses
+}
+
+// Join returns accesses of every list in order
+func Join(lists ...[]Access) []Access {
+	var accesses []Access
+	for _, list := range lists {
+		accesses = append(accesses, list...)
+	}
+	return accesses
+}
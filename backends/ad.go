@@ -12,6 +12,11 @@ type AD struct {
 	*Object
 }
 
+var adPaths = Paths{
+	Backup:  Join(RawReads("config"), Reads("roles/*")),
+	Restore: Writes("config", "roles/*"),
+}
+
 func (s *AD) Backup(ctx context.Context) error {
 	l := s.L.With(zap.String("method", "Restore"))
 
@@ -58,7 +63,7 @@ func (s *AD) Restore(ctx context.Context) error {
 		}
 
 		l.Debug("Write config to Vault")
-		if _, err := s.Vault.Write(ctx, path.Join(s.Engine.Path, "config"), payload); err != nil {
+		if err := s.VaultWrite(ctx, path.Join(s.Engine.Path, "config"), payload); err != nil {
 			return err
 		}
 	}
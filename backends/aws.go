@@ -12,6 +12,11 @@ type AWS struct {
 	*Object
 }
 
+var awsPaths = Paths{
+	Backup:  Join(RawReads("config/*"), Reads("config/lease", "roles/*")),
+	Restore: Writes("config/root", "config/lease", "roles/*"),
+}
+
 func (s *AWS) Backup(ctx context.Context) error {
 	l := s.L.With(zap.String("method", "Backup"))
 
@@ -52,7 +57,7 @@ func (s *AWS) Restore(ctx context.Context) error {
 				return err
 			}
 			l.Debug("Write root configuration to vault")
-			if _, err := s.Vault.Write(ctx, path.Join(s.Engine.Path, "config/root"), payload); err != nil {
+			if err := s.VaultWrite(ctx, path.Join(s.Engine.Path, "config/root"), payload); err != nil {
 				return err
 			}
 		}
@@ -69,7 +74,7 @@ func (s *AWS) Restore(ctx context.Context) error {
 				return err
 			}
 			l.Debug("Write lease configuration to vault")
-			if _, err := s.Vault.Write(ctx, path.Join(s.Engine.Path, "config/lease"), payload); err != nil {
+			if err := s.VaultWrite(ctx, path.Join(s.Engine.Path, "config/lease"), payload); err != nil {
 				return err
 			}
 		}
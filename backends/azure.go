@@ -0,0 +1,81 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"go.uber.org/zap"
+	"os"
+	"path"
+)
+
+type Azure struct {
+	*Object
+}
+
+var azurePaths = Paths{
+	Backup:  Join(RawReads("config"), Reads("config", "roles/*")),
+	Restore: Writes("config", "roles/*"),
+}
+
+func (s *Azure) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	// backup config, client secret is only reachable from storage
+	l.Debug("Start backup config")
+	keyPrefix := path.Join("logical", s.Engine.UUID)
+	if err := s.VaultBackupSingleKey(ctx, keyPrefix, "config", "client_secret"); err != nil {
+		return err
+	}
+
+	// backup roles
+	l.Debug("Start backup roles")
+	return s.VaultBackupRoles(ctx, "roles")
+}
+
+func (s *Azure) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	l.Debug("Start restore config")
+	if err := s.VaultRestoreSingleKey(ctx, "config"); err != nil {
+		return err
+	}
+
+	l.Debug("Start restore roles")
+	paths, err := s.LocalWalk(ctx, s.Options.RestorePath, "roles")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, p := range paths {
+		data, err := s.ReadFileAndB64Decode(ctx, p)
+		if err != nil {
+			return err
+		}
+
+		payload := map[string]interface{}{}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+
+		// roles and groups are returned as lists but written as JSON strings
+		for _, k := range []string{"azure_roles", "azure_groups"} {
+			if v, ok := payload[k]; ok && v != nil {
+				if _, isString := v.(string); !isString {
+					bs, err := json.Marshal(v)
+					if err != nil {
+						return err
+					}
+					payload[k] = string(bs)
+				}
+			}
+		}
+
+		vp := path.Join(s.Engine.Path, p)
+		l.Debug("Write data to vault", zap.String("path", vp))
+		if err := s.VaultWrite(ctx, vp, payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
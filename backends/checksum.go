@@ -0,0 +1,178 @@
+package backends
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// ChecksumIndexSuffix names the checksum index written next to an engine backup directory,
+// eg: backup/kv.kv2.sha256.json
+const ChecksumIndexSuffix = ".sha256.json"
+
+const (
+	SourceVault = "vault:"
+	SourceRaw   = "raw:"
+	SourceKV2   = "kv2:"
+)
+
+// ErrNoLiveSource is returned when a checksum can not be compared with Vault
+var ErrNoLiveSource = errors.New("checksum has no live source")
+
+// Checksum is the SHA-256 of one secret over its canonical JSON encoding, taken when it was read from Vault
+type Checksum struct {
+	// File is relative to the engine backup directory
+	File string `json:"file"`
+	// Key is set when File holds several secrets, eg: chunks of kv engines
+	Key string `json:"key,omitempty"`
+	// Encoding is base64 when the stored value is base64 encoded
+	Encoding string `json:"encoding,omitempty"`
+	// Source is where the secret can be read again: vault:<path>, raw:<storage key> or kv2:<key>
+	Source string `json:"source,omitempty"`
+	SHA256 string `json:"sha256"`
+}
+
+// ChecksumIndex holds the checksums of every secret of one engine backup
+type ChecksumIndex struct {
+	Path      string     `json:"path"`
+	Type      string     `json:"type"`
+	Checksums []Checksum `json:"checksums"`
+}
+
+// CanonicalJSON returns data with sorted keys and without insignificant whitespace,
+// data which is not JSON is returned unchanged
+func CanonicalJSON(data []byte) []byte {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil || d.More() {
+		return data
+	}
+
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return data
+	}
+	return bs
+}
+
+// Sum returns the hex encoded SHA-256 of the canonical JSON encoding of data
+func Sum(data []byte) string {
+	h := sha256.Sum256(CanonicalJSON(data))
+	return hex.EncodeToString(h[:])
+}
+
+// AddChecksum records the checksum of content, it is written to the index by WriteChecksumIndex
+func (o *Object) AddChecksum(c Checksum, content []byte) {
+	c.SHA256 = Sum(content)
+	o.checksums = append(o.checksums, c)
+}
+
+// WriteChecksumIndex writes checksums recorded during backup next to the engine backup directory
+func (o *Object) WriteChecksumIndex() error {
+	if o.Options.BackupPath == "" {
+		return nil
+	}
+
+	index := ChecksumIndex{
+		Path:      o.Engine.Path,
+		Type:      strings.TrimPrefix(path.Ext(o.Options.BackupPath), "."),
+		Checksums: o.checksums,
+	}
+
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(o.Options.BackupPath+ChecksumIndexSuffix, content, 0644)
+}
+
+// LiveChecksum reads the secret again from Vault, it returns an empty checksum when the secret is gone
+func (o *Object) LiveChecksum(ctx context.Context, c Checksum) (string, error) {
+	switch {
+	case strings.HasPrefix(c.Source, SourceVault):
+		data, err := o.Vault.Read(ctx, strings.TrimPrefix(c.Source, SourceVault))
+		if err != nil {
+			if strings.HasPrefix(err.Error(), "404") {
+				return "", nil
+			}
+			return "", err
+		}
+		if data == nil || data.Data == nil {
+			return "", nil
+		}
+
+		bs, err := json.Marshal(data.Data)
+		if err != nil {
+			return "", err
+		}
+		return Sum(bs), nil
+
+	case strings.HasPrefix(c.Source, SourceRaw):
+		if !o.Options.RawAccessible {
+			return "", ErrNoLiveSource
+		}
+
+		rdata, err := o.Vault.System.RawRead(ctx, strings.TrimPrefix(c.Source, SourceRaw))
+		if err != nil {
+			if strings.Contains(err.Error(), "being decompressed is empty") || strings.HasPrefix(err.Error(), "404") {
+				return "", nil
+			}
+			return "", err
+		}
+		return Sum([]byte(rdata.Data.Value)), nil
+	}
+
+	return "", ErrNoLiveSource
+}
+
+// ReadChecksumIndex reads the checksum index of an engine backup directory
+func ReadChecksumIndex(dir string) (*ChecksumIndex, error) {
+	content, err := os.ReadFile(dir + ChecksumIndexSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	var index ChecksumIndex
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
+
+// FileChecksum hashes the secret of c again from the engine backup directory
+func FileChecksum(dir string, c Checksum) (string, error) {
+	content, err := os.ReadFile(path.Join(dir, c.File))
+	if err != nil {
+		return "", err
+	}
+
+	if c.Key != "" {
+		chunk := map[string]string{}
+		if err := json.Unmarshal(content, &chunk); err != nil {
+			return "", err
+		}
+		value, ok := chunk[c.Key]
+		if !ok {
+			return "", fmt.Errorf("key '%v' not found in '%v'", c.Key, c.File)
+		}
+		content = []byte(value)
+	}
+
+	if c.Encoding == "base64" {
+		if content, err = base64.StdEncoding.DecodeString(string(content)); err != nil {
+			return "", err
+		}
+	}
+
+	return Sum(content), nil
+}
@@ -0,0 +1,41 @@
+package backends
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"path"
+)
+
+type Consul struct {
+	*Object
+}
+
+var consulPaths = Paths{
+	Backup:  Join(RawReads("config/access"), Reads("config/access", "roles/*")),
+	Restore: Writes("config/access", "roles/*"),
+}
+
+func (s *Consul) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	l.Debug("Start backup access configuration")
+	keyPrefix := path.Join("logical", s.Engine.UUID)
+	if err := s.VaultBackupSingleKey(ctx, keyPrefix, "config/access", "token", "ca_cert", "client_cert", "client_key"); err != nil {
+		return err
+	}
+
+	l.Debug("Start backup roles")
+	return s.VaultBackupRoles(ctx, "roles")
+}
+
+func (s *Consul) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	l.Debug("Start restore access configuration")
+	if err := s.VaultRestoreSingleKey(ctx, "config/access"); err != nil {
+		return err
+	}
+
+	l.Debug("Start restore roles")
+	return s.VaultRestoreRoles(ctx, "roles")
+}
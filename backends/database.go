@@ -12,6 +12,11 @@ type Database struct {
 	*Object
 }
 
+var databasePaths = Paths{
+	Backup:  Join(RawReads("config/*"), Reads("roles/*")),
+	Restore: Writes("config/*", "roles/*"),
+}
+
 //type DatabaseRole struct {
 //	DBName               string                 `json:"db_name"`
 //	DefaultTTL           int                    `json:"default_ttl"`
@@ -126,7 +131,7 @@ func (s *Database) Restore(ctx context.Context) error {
 
 		vp := path.Join(s.Engine.Path, p)
 		l.Debug("Write data to Vault", zap.String("path", vp))
-		if _, err := s.Vault.Write(ctx, vp, payload); err != nil {
+		if err := s.VaultWrite(ctx, vp, payload); err != nil {
 			return err
 		}
 	}
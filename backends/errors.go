@@ -0,0 +1,86 @@
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"strings"
+)
+
+// KeyError is a key an engine could not back up or restore
+type KeyError struct {
+	Key string
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("key '%v': %v", e.Key, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// EngineError is the failure of an engine, Err is nil when only some keys failed
+type EngineError struct {
+	Operation string
+	Namespace string
+	Path      string
+	Type      string
+	Err       error
+	Keys      []KeyError
+}
+
+func (e *EngineError) Error() string {
+	where := e.Path
+	if e.Namespace != "" {
+		where = e.Namespace + "/" + e.Path
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%v of '%v' failed: %v", e.Operation, where, e.Err)
+	}
+	return fmt.Sprintf("%v of '%v' failed for %d keys", e.Operation, where, len(e.Keys))
+}
+
+func (e *EngineError) Unwrap() error {
+	return e.Err
+}
+
+// Partial reports whether the engine went through and only some keys failed
+func (e *EngineError) Partial() bool {
+	return e.Err == nil && len(e.Keys) > 0
+}
+
+// Skip records a failed key and returns nil when Options.ContinueOnError is set, the error otherwise
+func (o *Object) Skip(key string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var ke *KeyError
+	if !errors.As(err, &ke) {
+		ke = &KeyError{Key: strings.TrimPrefix(key, "/"), Err: err}
+	}
+	if !o.Options.ContinueOnError {
+		return ke
+	}
+
+	o.L.Warn("Skip failed key", zap.String("key", ke.Key), zap.Error(ke.Err))
+	o.keyErrors = append(o.keyErrors, *ke)
+	return nil
+}
+
+// EachKey calls fn for every key, failed keys are skipped when Options.ContinueOnError is set
+func (o *Object) EachKey(keys []string, fn func(key string) error) error {
+	for _, key := range keys {
+		if err := o.Skip(key, fn(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KeyErrors returns the keys skipped by Skip
+func (o *Object) KeyErrors() []KeyError {
+	return o.keyErrors
+}
@@ -0,0 +1,101 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"go.uber.org/zap"
+	"os"
+	"path"
+)
+
+type GCP struct {
+	*Object
+}
+
+var gcpPaths = Paths{
+	Backup:  Join(RawReads("config"), Reads("config", "rolesets/*", "roleset/*", "static-accounts/*", "static-account/*")),
+	Restore: Writes("config", "roleset/*", "static-account/*"),
+}
+
+// gcpItems maps list endpoints to item endpoints of rolesets and static accounts
+var gcpItems = [][2]string{
+	{"rolesets", "roleset"},
+	{"static-accounts", "static-account"},
+}
+
+func (s *GCP) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	// backup config, credentials are only reachable from storage
+	l.Debug("Start backup config")
+	keyPrefix := path.Join("logical", s.Engine.UUID)
+	if err := s.VaultBackupSingleKey(ctx, keyPrefix, "config", "credentials"); err != nil {
+		return err
+	}
+
+	for _, item := range gcpItems {
+		l.Debug("Start backup", zap.String("path", item[0]))
+		if err := s.VaultBackupItems(ctx, item[0], item[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *GCP) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	l.Debug("Start restore config")
+	if err := s.VaultRestoreSingleKey(ctx, "config"); err != nil {
+		return err
+	}
+
+	for _, item := range gcpItems {
+		l.Debug("Start restore", zap.String("path", item[1]))
+		paths, err := s.LocalWalk(ctx, s.Options.RestorePath, item[1])
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		for _, p := range paths {
+			data, err := s.ReadFileAndB64Decode(ctx, p)
+			if err != nil {
+				return err
+			}
+
+			payload := map[string]interface{}{}
+			if err := json.Unmarshal(data, &payload); err != nil {
+				return err
+			}
+
+			// bindings are returned as resource => roles but written as HCL or JSON,
+			// they are expected to read back as returned
+			expected := map[string]interface{}{}
+			for k, v := range payload {
+				expected[k] = v
+			}
+
+			if bindings, ok := payload["bindings"].(map[string]interface{}); ok {
+				resources := map[string]interface{}{}
+				for resource, roles := range bindings {
+					resources[resource] = map[string]interface{}{"roles": roles}
+				}
+				bs, err := json.Marshal(map[string]interface{}{"resource": resources})
+				if err != nil {
+					return err
+				}
+				payload["bindings"] = string(bs)
+			}
+
+			vp := path.Join(s.Engine.Path, p)
+			l.Debug("Write data to vault", zap.String("path", vp))
+			if err := s.VaultWriteOnly(ctx, vp, payload); err != nil {
+				return err
+			}
+			s.Expect(Expectation{Path: vp, Data: expected})
+		}
+	}
+
+	return nil
+}
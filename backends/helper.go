@@ -11,6 +11,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 )
 
 type Object struct {
@@ -18,6 +19,12 @@ type Object struct {
 	Engine  *SecretEngine
 	Options *Options
 	L       *zap.Logger
+
+	checksums    []Checksum
+	expectations []Expectation
+	keyErrors    []KeyError
+	bytes        atomic.Int64
+	writes       atomic.Int64
 }
 
 func (o *Object) RawBackupSingleKey(ctx context.Context, keyPrefix, key string) error {
@@ -33,20 +40,13 @@ func (o *Object) RawBackupSingleKey(ctx context.Context, keyPrefix, key string)
 		return err
 	}
 
-	of := path.Join(o.Options.BackupPath, key)
-	l.Debug("Create local file", zap.String("path", of))
-	f, err := os.Create(of)
-	if err != nil {
-		return nil
-	}
-
 	value := base64.StdEncoding.EncodeToString([]byte(rdata.Data.Value))
 
-	l.Debug("Write data to local file", zap.String("path", of))
-	if _, err = f.WriteString(value); err != nil {
+	if err := o.writeFile(ctx, key, []byte(value)); err != nil {
 		return err
 	}
-	_ = f.Close()
+
+	o.AddChecksum(Checksum{File: key, Encoding: "base64", Source: SourceRaw + vp}, []byte(rdata.Data.Value))
 	return nil
 }
 
@@ -68,7 +68,12 @@ func (o *Object) RawRestoreSingleKey(ctx context.Context, keyPrefix, key string)
 		Encoding: "base64",
 		Value:    string(content),
 	}); err != nil {
-		return nil
+		return err
+	}
+	o.writes.Add(1)
+
+	if value, err := base64.StdEncoding.DecodeString(string(content)); err == nil {
+		o.Expect(Expectation{Path: vp, Raw: true, Value: string(value)})
 	}
 	return nil
 }
@@ -96,23 +101,16 @@ func (o *Object) RawBackup(ctx context.Context, keyPrefix, subKey string) error
 		return err
 	}
 
-	for _, key := range resp.Data.Keys {
+	return o.EachKey(resp.Data.Keys, func(key string) error {
 		l.Debug("Start backup process", zap.String("key", key))
 		// check if key is a folder
 		if strings.HasSuffix(key, "/") {
 			l.Debug("key is folder, checking inside", zap.String("key", key))
-			if err := o.RawBackup(ctx, keyPrefix, path.Join(subKey, key)); err != nil {
-				return err
-			}
-			continue
+			return o.RawBackup(ctx, keyPrefix, path.Join(subKey, key))
 		}
 
-		if err := o.RawBackupSingleKey(ctx, keyPrefix, path.Join(subKey, key)); err != nil {
-			return err
-		}
-	}
-
-	return nil
+		return o.RawBackupSingleKey(ctx, keyPrefix, path.Join(subKey, key))
+	})
 }
 
 func (o *Object) RawRestore(ctx context.Context, keyPrefix, subKey string) error {
@@ -134,14 +132,14 @@ func (o *Object) RawRestore(ctx context.Context, keyPrefix, subKey string) error
 	for _, file := range files {
 		fp := path.Join(subKey, file.Name())
 		if file.IsDir() {
-			if err := o.RawRestore(ctx, keyPrefix, fp); err != nil {
+			if err := o.Skip(fp, o.RawRestore(ctx, keyPrefix, fp)); err != nil {
 				return err
 			}
 			continue
 		}
 
 		l.Debug("Start restore process", zap.String("file", fp))
-		if err := o.RawRestoreSingleKey(ctx, keyPrefix, fp); err != nil {
+		if err := o.Skip(fp, o.RawRestoreSingleKey(ctx, keyPrefix, fp)); err != nil {
 			return err
 		}
 	}
@@ -226,8 +224,8 @@ func (o *Object) ReadFileAndB64Decode(ctx context.Context, f string) ([]byte, er
 	return bd, nil
 }
 
-func (o *Object) WriteData(ctx context.Context, fp string, content []byte) error {
-	l := o.L.With(zap.String("method", "WriteData"))
+func (o *Object) writeFile(ctx context.Context, fp string, content []byte) error {
+	l := o.L.With(zap.String("method", "writeFile"))
 
 	of := path.Join(o.Options.BackupPath, fp)
 	l.Debug("Create parent directory if needed", zap.String("path", of))
@@ -246,19 +244,44 @@ func (o *Object) WriteData(ctx context.Context, fp string, content []byte) error
 	if _, err = f.Write(content); err != nil {
 		return err
 	}
+	o.bytes.Add(int64(len(content)))
 
 	_ = f.Close()
 	return nil
 }
 
+// WriteData writes content as is and records its checksum
+func (o *Object) WriteData(ctx context.Context, fp string, content []byte) error {
+	if err := o.writeFile(ctx, fp, content); err != nil {
+		return err
+	}
+
+	o.AddChecksum(Checksum{File: fp}, content)
+	return nil
+}
+
 func (o *Object) WriteB64Data(ctx context.Context, fp string, content []byte) error {
+	return o.writeB64Data(ctx, fp, "", content)
+}
+
+func (o *Object) writeB64Data(ctx context.Context, fp, source string, content []byte) error {
 	l := o.L.With(zap.String("method", "WriteB64Data"))
 	l.Debug("Encode base64 data", zap.String("path", fp))
 	output := base64.StdEncoding.EncodeToString(content)
-	return o.WriteData(ctx, fp, []byte(output))
+	if err := o.writeFile(ctx, fp, []byte(output)); err != nil {
+		return err
+	}
+
+	o.AddChecksum(Checksum{File: fp, Encoding: "base64", Source: source}, content)
+	return nil
 }
 
 func (o *Object) WriteVaultResponse(ctx context.Context, fp string, data map[string]interface{}) error {
+	return o.WriteVaultResponseFrom(ctx, fp, "", data)
+}
+
+// WriteVaultResponseFrom writes data read from source, eg: vault:<path>, so it can be verified again later
+func (o *Object) WriteVaultResponseFrom(ctx context.Context, fp, source string, data map[string]interface{}) error {
 	l := o.L.With(zap.String("method", "WriteVaultResponse"))
 	l.Debug("Marshal data", zap.String("path", fp))
 	content, err := json.Marshal(data)
@@ -266,7 +289,7 @@ func (o *Object) WriteVaultResponse(ctx context.Context, fp string, data map[str
 		return nil
 	}
 
-	return o.WriteB64Data(ctx, fp, content)
+	return o.writeB64Data(ctx, fp, source, content)
 }
 
 func (o *Object) VaultRestoreRoles(ctx context.Context, dir string) error {
@@ -278,7 +301,7 @@ func (o *Object) VaultRestoreRoles(ctx context.Context, dir string) error {
 		return err
 	}
 
-	for _, p := range paths {
+	return o.EachKey(paths, func(p string) error {
 		l.Debug("Read local file and decode base64", zap.String("path", p))
 		data, err := o.ReadFileAndB64Decode(ctx, p)
 		if err != nil {
@@ -292,11 +315,8 @@ func (o *Object) VaultRestoreRoles(ctx context.Context, dir string) error {
 
 		vp := path.Join(o.Engine.Path, p)
 		l.Debug("Write data to vault", zap.String("path", vp))
-		if _, err := o.Vault.Write(ctx, vp, payload); err != nil {
-			return err
-		}
-	}
-	return nil
+		return o.VaultWrite(ctx, vp, payload)
+	})
 }
 
 func (o *Object) VaultBackupRoles(ctx context.Context, dir string) error {
@@ -308,7 +328,7 @@ func (o *Object) VaultBackupRoles(ctx context.Context, dir string) error {
 		return err
 	}
 
-	for _, p := range paths {
+	return o.EachKey(paths, func(p string) error {
 		vp := path.Join(o.Engine.Path, p)
 
 		l.Debug("Read data from vault", zap.String("path", vp))
@@ -318,11 +338,107 @@ func (o *Object) VaultBackupRoles(ctx context.Context, dir string) error {
 		}
 
 		l.Debug("Process vault response")
-		if err := o.WriteVaultResponse(ctx, p, data.Data); err != nil {
+		return o.WriteVaultResponseFrom(ctx, p, SourceVault+vp, data.Data)
+	})
+}
+
+// VaultBackupSingleKey backs up one key read through the API. When raw is accessible, write-only fields
+// which Vault does not return are completed from storage at keyPrefix/key.
+// keyPrefix: logical/<uuid> or auth/<uuid>
+// key: config or config/access
+func (o *Object) VaultBackupSingleKey(ctx context.Context, keyPrefix, key string, rawFields ...string) error {
+	l := o.L.With(zap.String("method", "VaultBackupSingleKey"))
+
+	vp := path.Join(o.Engine.Path, key)
+	l.Debug("Read data from vault", zap.String("path", vp))
+	data, err := o.Vault.Read(ctx, vp)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "404") {
+			l.Debug("key does not exist", zap.String("path", vp))
+			return nil
+		}
+		return err
+	}
+
+	if data == nil || data.Data == nil {
+		l.Debug("key is empty", zap.String("path", vp))
+		return nil
+	}
+
+	// data completed from storage does not match what Vault returns
+	source := SourceVault + vp
+	if o.Options.RawAccessible && len(rawFields) > 0 {
+		source = ""
+		rp := path.Join(keyPrefix, key)
+		l.Debug("Read raw data", zap.String("path", rp))
+		rdata, err := o.Vault.System.RawRead(ctx, rp)
+		if err != nil {
 			return err
 		}
 
+		stored := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(rdata.Data.Value), &stored); err != nil {
+			return err
+		}
+
+		for _, field := range rawFields {
+			if v, ok := stored[field]; ok {
+				data.Data[field] = v
+			}
+		}
+	}
+
+	return o.WriteVaultResponseFrom(ctx, key, source, data.Data)
+}
+
+// VaultRestoreSingleKey writes a key backed up by VaultBackupSingleKey, a missing key is skipped
+func (o *Object) VaultRestoreSingleKey(ctx context.Context, key string) error {
+	l := o.L.With(zap.String("method", "VaultRestoreSingleKey"))
+
+	l.Debug("Read local file and decode base64", zap.String("path", key))
+	data, err := o.ReadFileAndB64Decode(ctx, key)
+	if err != nil {
+		if os.IsNotExist(err) {
+			l.Warn("key not found in backup, skip restore", zap.String("key", key))
+			return nil
+		}
+		return err
+	}
+
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
 	}
 
+	vp := path.Join(o.Engine.Path, key)
+	l.Debug("Write data to vault", zap.String("path", vp))
+	if err := o.VaultWrite(ctx, vp, payload); err != nil {
+		return err
+	}
 	return nil
 }
+
+// VaultBackupItems backs up items listed at listDir and read at itemDir, eg: rolesets and roleset/<name>,
+// items are stored as itemDir/<name>
+func (o *Object) VaultBackupItems(ctx context.Context, listDir, itemDir string) error {
+	l := o.L.With(zap.String("method", "VaultBackupItems"))
+
+	l.Debug("List all vault paths", zap.String("path", path.Join(o.Engine.Path, listDir)))
+	paths, err := o.VaultWalk(ctx, o.Engine.Path, listDir)
+	if err != nil {
+		return err
+	}
+
+	return o.EachKey(paths, func(p string) error {
+		key := path.Join(itemDir, strings.TrimPrefix(p, listDir+"/"))
+		vp := path.Join(o.Engine.Path, key)
+
+		l.Debug("Read data from vault", zap.String("path", vp))
+		data, err := o.Vault.Read(ctx, vp)
+		if err != nil {
+			return err
+		}
+
+		return o.WriteVaultResponseFrom(ctx, key, SourceVault+vp, data.Data)
+	})
+}
@@ -0,0 +1,433 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"go.uber.org/zap"
+	"os"
+	"path"
+)
+
+type Identity struct {
+	*Object
+}
+
+// identityPaths read entities and groups back by name to remap their ids
+var identityPaths = Paths{
+	Backup:  Reads("entity/id/*", "group/id/*", "oidc/*"),
+	Restore: Join(Reads("entity/name/*", "group/name/*"), Writes("entity/name/*", "group/name/*", "entity-alias", "group-alias", "lookup/*", "oidc/*")),
+}
+
+type IdentityAlias struct {
+	Name           string            `json:"name"`
+	MountPath      string            `json:"mount_path"`
+	MountAccessor  string            `json:"mount_accessor"`
+	CustomMetadata map[string]string `json:"custom_metadata"`
+}
+
+type IdentityEntity struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Metadata map[string]string `json:"metadata"`
+	Policies []string          `json:"policies"`
+	Disabled bool              `json:"disabled"`
+	Aliases  []IdentityAlias   `json:"aliases"`
+}
+
+type IdentityGroup struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Type            string            `json:"type"`
+	Metadata        map[string]string `json:"metadata"`
+	Policies        []string          `json:"policies"`
+	MemberEntityIDs []string          `json:"member_entity_ids"`
+	MemberGroupIDs  []string          `json:"member_group_ids"`
+	Alias           IdentityAlias     `json:"alias"`
+}
+
+// identityOIDCDirs are restored in this order, roles need keys, clients need keys and assignments
+// and providers need scopes and clients. allowed_client_ids of keys are remapped once clients got
+// their new ids, see restoreOIDCKeyClients
+var identityOIDCDirs = []string{"oidc/key", "oidc/role", "oidc/scope", "oidc/assignment", "oidc/client", "oidc/provider"}
+
+func (s *Identity) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	l.Debug("Start backup entities")
+	if err := s.backupByName(ctx, "entity"); err != nil {
+		return err
+	}
+
+	l.Debug("Start backup groups")
+	if err := s.backupByName(ctx, "group"); err != nil {
+		return err
+	}
+
+	l.Debug("Start backup oidc configuration")
+	if err := s.VaultBackupSingleKey(ctx, "", "oidc/config"); err != nil {
+		return err
+	}
+
+	for _, dir := range identityOIDCDirs {
+		l.Debug("Start backup oidc", zap.String("path", dir))
+		if err := s.VaultBackupRoles(ctx, dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backupByName lists entities or groups by id and writes them as <kind>/<name>,
+// ids are generated by Vault and can not be kept on restore
+func (s *Identity) backupByName(ctx context.Context, kind string) error {
+	l := s.L.With(zap.String("method", "backupByName"))
+
+	paths, err := s.VaultWalk(ctx, s.Engine.Path, path.Join(kind, "id"))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		vp := path.Join(s.Engine.Path, p)
+		l.Debug("Read data from vault", zap.String("path", vp))
+		data, err := s.Vault.Read(ctx, vp)
+		if err != nil {
+			return err
+		}
+
+		name, _ := data.Data["name"].(string)
+		if err := s.WriteVaultResponse(ctx, path.Join(kind, name), data.Data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Identity) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	accessors, err := s.authAccessors(ctx)
+	if err != nil {
+		return err
+	}
+
+	l.Debug("Start restore entities")
+	entityIDs, err := s.restoreEntities(ctx, accessors)
+	if err != nil {
+		return err
+	}
+
+	l.Debug("Start restore groups")
+	groupIDs, err := s.restoreGroups(ctx, accessors, entityIDs)
+	if err != nil {
+		return err
+	}
+
+	l.Debug("Start restore oidc configuration")
+	if err := s.VaultRestoreSingleKey(ctx, "oidc/config"); err != nil {
+		return err
+	}
+
+	// clients get new ids on the target, roles keep theirs
+	clientIDs := map[string]string{"*": "*"}
+	for _, dir := range identityOIDCDirs {
+		l.Debug("Start restore oidc", zap.String("path", dir))
+		if err := s.restoreOIDC(ctx, dir, entityIDs, groupIDs, clientIDs); err != nil {
+			return err
+		}
+	}
+
+	return s.restoreOIDCKeyClients(ctx, clientIDs)
+}
+
+// authAccessors maps auth mount paths of the target cluster, eg: auth/userpass/, to their accessor
+func (s *Identity) authAccessors(ctx context.Context) (map[string]string, error) {
+	methods, err := s.Vault.System.AuthListEnabledMethods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	accessors := map[string]string{}
+	for key, value := range methods.Data {
+		if m, ok := value.(map[string]interface{}); ok {
+			accessors[path.Join("auth", key)+"/"], _ = m["accessor"].(string)
+		}
+	}
+	return accessors, nil
+}
+
+func (s *Identity) readBackup(ctx context.Context, p string, v interface{}) error {
+	data, err := s.ReadFileAndB64Decode(ctx, p)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeByName creates or updates an entity or group by name and returns its id in the target cluster
+func (s *Identity) writeByName(ctx context.Context, kind, name string, payload map[string]interface{}) (string, error) {
+	vp := path.Join(s.Engine.Path, kind, "name", name)
+	if err := s.VaultWrite(ctx, vp, payload); err != nil {
+		return "", err
+	}
+
+	data, err := s.Vault.Read(ctx, vp)
+	if err != nil {
+		return "", err
+	}
+	id, _ := data.Data["id"].(string)
+	return id, nil
+}
+
+// restoreEntities returns the mapping of backup entity ids to target entity ids
+func (s *Identity) restoreEntities(ctx context.Context, accessors map[string]string) (map[string]string, error) {
+	l := s.L.With(zap.String("method", "restoreEntities"))
+	ids := map[string]string{}
+
+	paths, err := s.LocalWalk(ctx, s.Options.RestorePath, "entity")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, p := range paths {
+		var entity IdentityEntity
+		if err := s.readBackup(ctx, p, &entity); err != nil {
+			return nil, err
+		}
+
+		l.Debug("Write entity to vault", zap.String("name", entity.Name))
+		id, err := s.writeByName(ctx, "entity", entity.Name, map[string]interface{}{
+			"metadata": entity.Metadata,
+			"policies": entity.Policies,
+			"disabled": entity.Disabled,
+		})
+		if err != nil {
+			return nil, err
+		}
+		ids[entity.ID] = id
+
+		for _, alias := range entity.Aliases {
+			if err := s.restoreAlias(ctx, "entity-alias", id, alias, accessors); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+// restoreAlias creates an entity or group alias on the auth mount with the same path in the target cluster
+func (s *Identity) restoreAlias(ctx context.Context, kind, canonicalID string, alias IdentityAlias, accessors map[string]string) error {
+	l := s.L.With(zap.String("method", "restoreAlias"))
+
+	accessor, ok := accessors[alias.MountPath]
+	if !ok {
+		l.Warn("Auth mount not found in target, skip restore alias", zap.String("alias", alias.Name), zap.String("mount-path", alias.MountPath))
+		return nil
+	}
+
+	lookup := "entity"
+	if kind == "group-alias" {
+		lookup = "group"
+	}
+
+	data, err := s.Vault.Write(ctx, path.Join(s.Engine.Path, "lookup", lookup), map[string]interface{}{
+		"alias_name":           alias.Name,
+		"alias_mount_accessor": accessor,
+	})
+	if err != nil {
+		return err
+	}
+	if data != nil && data.Data != nil {
+		l.Debug("Alias already exists", zap.String("alias", alias.Name), zap.String("mount-path", alias.MountPath))
+		return nil
+	}
+
+	payload := map[string]interface{}{
+		"name":           alias.Name,
+		"canonical_id":   canonicalID,
+		"mount_accessor": accessor,
+	}
+	if kind == "entity-alias" {
+		payload["custom_metadata"] = alias.CustomMetadata
+	}
+
+	l.Debug("Write alias to vault", zap.String("alias", alias.Name), zap.String("mount-path", alias.MountPath))
+	if err := s.VaultWriteOnly(ctx, path.Join(s.Engine.Path, kind), payload); err != nil {
+		return err
+	}
+	return nil
+}
+
+// restoreGroups returns the mapping of backup group ids to target group ids,
+// member groups are set once every group exists
+func (s *Identity) restoreGroups(ctx context.Context, accessors, entityIDs map[string]string) (map[string]string, error) {
+	l := s.L.With(zap.String("method", "restoreGroups"))
+	ids := map[string]string{}
+
+	paths, err := s.LocalWalk(ctx, s.Options.RestorePath, "group")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var groups []IdentityGroup
+	for _, p := range paths {
+		var group IdentityGroup
+		if err := s.readBackup(ctx, p, &group); err != nil {
+			return nil, err
+		}
+
+		payload := map[string]interface{}{
+			"type":     group.Type,
+			"metadata": group.Metadata,
+			"policies": group.Policies,
+		}
+		if group.Type != "external" {
+			payload["member_entity_ids"] = remapIDs(group.MemberEntityIDs, entityIDs)
+		}
+
+		l.Debug("Write group to vault", zap.String("name", group.Name))
+		id, err := s.writeByName(ctx, "group", group.Name, payload)
+		if err != nil {
+			return nil, err
+		}
+		ids[group.ID] = id
+		groups = append(groups, group)
+
+		if group.Type == "external" && group.Alias.Name != "" {
+			if err := s.restoreAlias(ctx, "group-alias", id, group.Alias, accessors); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, group := range groups {
+		if group.Type == "external" || len(group.MemberGroupIDs) == 0 {
+			continue
+		}
+
+		l.Debug("Write member groups to vault", zap.String("name", group.Name))
+		if _, err := s.writeByName(ctx, "group", group.Name, map[string]interface{}{
+			"member_group_ids": remapIDs(group.MemberGroupIDs, ids),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *Identity) restoreOIDC(ctx context.Context, dir string, entityIDs, groupIDs, clientIDs map[string]string) error {
+	l := s.L.With(zap.String("method", "restoreOIDC"))
+
+	paths, err := s.LocalWalk(ctx, s.Options.RestorePath, dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, p := range paths {
+		payload := map[string]interface{}{}
+		if err := s.readBackup(ctx, p, &payload); err != nil {
+			return err
+		}
+
+		var clientID string
+		switch dir {
+		case "oidc/role":
+			if id, _ := payload["client_id"].(string); id != "" {
+				clientIDs[id] = id
+			}
+		case "oidc/client":
+			// Vault generates the credentials of clients
+			clientID, _ = payload["client_id"].(string)
+			delete(payload, "client_id")
+			delete(payload, "client_secret")
+		case "oidc/assignment":
+			// built-in assignment can not be modified
+			if path.Base(p) == "allow_all" {
+				continue
+			}
+			payload["entity_ids"] = remapIDs(toStrings(payload["entity_ids"]), entityIDs)
+			payload["group_ids"] = remapIDs(toStrings(payload["group_ids"]), groupIDs)
+		case "oidc/provider":
+			// issuer is returned with the source cluster address and provider path,
+			// the target cluster derives it from its own api_addr
+			delete(payload, "issuer")
+			payload["allowed_client_ids"] = remapIDs(toStrings(payload["allowed_client_ids"]), clientIDs)
+		}
+
+		vp := path.Join(s.Engine.Path, p)
+		l.Debug("Write data to vault", zap.String("path", vp))
+		write := s.VaultWrite
+		// roles need their key to allow them, keys are read back once their clients are remapped
+		if dir == "oidc/key" {
+			write = s.VaultWriteOnly
+		}
+		if err := write(ctx, vp, payload); err != nil {
+			return err
+		}
+
+		if clientID != "" {
+			data, err := s.Vault.Read(ctx, vp)
+			if err != nil {
+				return err
+			}
+			if id, _ := data.Data["client_id"].(string); id != "" {
+				clientIDs[clientID] = id
+			}
+		}
+	}
+
+	return nil
+}
+
+// restoreOIDCKeyClients writes keys again with allowed_client_ids of restored roles and clients
+func (s *Identity) restoreOIDCKeyClients(ctx context.Context, clientIDs map[string]string) error {
+	l := s.L.With(zap.String("method", "restoreOIDCKeyClients"))
+
+	paths, err := s.LocalWalk(ctx, s.Options.RestorePath, "oidc/key")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, p := range paths {
+		payload := map[string]interface{}{}
+		if err := s.readBackup(ctx, p, &payload); err != nil {
+			return err
+		}
+
+		payload["allowed_client_ids"] = remapIDs(toStrings(payload["allowed_client_ids"]), clientIDs)
+		vp := path.Join(s.Engine.Path, p)
+		l.Debug("Write allowed clients to vault", zap.String("path", vp))
+		if err := s.VaultWrite(ctx, vp, payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// remapIDs translates backup ids to target ids, unknown ids are dropped
+func remapIDs(ids []string, mapping map[string]string) []string {
+	output := []string{}
+	for _, id := range ids {
+		if v, ok := mapping[id]; ok {
+			output = append(output, v)
+		}
+	}
+	return output
+}
+
+func toStrings(v interface{}) []string {
+	var output []string
+	if values, ok := v.([]interface{}); ok {
+		for _, value := range values {
+			if s, ok := value.(string); ok {
+				output = append(output, s)
+			}
+		}
+	}
+	return output
+}
@@ -0,0 +1,43 @@
+package backends
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"path"
+)
+
+type Kubernetes struct {
+	*Object
+}
+
+var kubernetesPaths = Paths{
+	Backup:  Join(RawReads("config"), Reads("config", "roles/*")),
+	Restore: Writes("config", "roles/*"),
+}
+
+func (s *Kubernetes) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	// backup config, service account JWT is only reachable from storage
+	l.Debug("Start backup config")
+	keyPrefix := path.Join("logical", s.Engine.UUID)
+	if err := s.VaultBackupSingleKey(ctx, keyPrefix, "config", "service_account_jwt"); err != nil {
+		return err
+	}
+
+	// backup roles
+	l.Debug("Start backup roles")
+	return s.VaultBackupRoles(ctx, "roles")
+}
+
+func (s *Kubernetes) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	l.Debug("Start restore config")
+	if err := s.VaultRestoreSingleKey(ctx, "config"); err != nil {
+		return err
+	}
+
+	l.Debug("Start restore roles")
+	return s.VaultRestoreRoles(ctx, "roles")
+}
@@ -0,0 +1,102 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"go.uber.org/zap"
+	"os"
+	"path"
+)
+
+// LDAP handles the ldap secrets engine, formerly openldap
+type LDAP struct {
+	*Object
+}
+
+var ldapPaths = Paths{
+	Backup:  Join(RawReads("config"), Reads("config", "role/*", "static-role/*", "library/*")),
+	Restore: Writes("config", "role/*", "static-role/*", "library/*"),
+}
+
+func (s *LDAP) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	// backup config, bind password is only reachable from storage
+	if s.Options.RawAccessible {
+		l.Debug("Start backup config")
+		keyPrefix := path.Join("logical", s.Engine.UUID)
+		if err := s.RawBackupSingleKey(ctx, keyPrefix, "config"); err != nil {
+			return err
+		}
+	} else {
+		l.Warn("sys/raw is not accessible, config is backed up without bind password and can not be restored")
+		if err := s.VaultBackupSingleKey(ctx, "", "config"); err != nil {
+			return err
+		}
+	}
+
+	// backup dynamic roles, static roles and library sets
+	for _, dir := range []string{"role", "static-role", "library"} {
+		l.Debug("Start backup roles", zap.String("path", dir))
+		if err := s.VaultBackupRoles(ctx, dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *LDAP) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	l.Debug("Start restore config")
+	l.Debug("Read and decode config file")
+	data, err := s.ReadFileAndB64Decode(ctx, "config")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if os.IsNotExist(err) {
+		l.Warn("No config file found, skip restore LDAP configuration")
+	} else {
+		l.Info("Restore LDAP configuration")
+		config := map[string]interface{}{}
+		l.Debug("Unmarshal config file")
+		if err := json.Unmarshal(data, &config); err != nil {
+			return err
+		}
+
+		// storage keeps the connection settings under ldap, the API takes them flat
+		payload := map[string]interface{}{}
+		for k, v := range config {
+			switch k {
+			case "ldap":
+				for lk, lv := range v.(map[string]interface{}) {
+					payload[lk] = lv
+				}
+			case "last_bind_password", "last_bind_password_rotation":
+			default:
+				payload[k] = v
+			}
+		}
+
+		// Vault refuses a config without bind password, backups taken without sys/raw lack it
+		if _, ok := payload["bindpass"]; !ok {
+			l.Warn("Config has no bind password, skip restore LDAP configuration")
+		} else {
+			l.Debug("Write config to Vault")
+			if err := s.VaultWrite(ctx, path.Join(s.Engine.Path, "config"), payload); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, dir := range []string{"role", "static-role", "library"} {
+		l.Debug("Start restore roles", zap.String("path", dir))
+		if err := s.VaultRestoreRoles(ctx, dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
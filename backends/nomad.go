@@ -0,0 +1,51 @@
+package backends
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"path"
+)
+
+type Nomad struct {
+	*Object
+}
+
+var nomadPaths = Paths{
+	Backup:  Join(RawReads("config/access"), Reads("config/access", "config/lease", "role/*")),
+	Restore: Writes("config/access", "config/lease", "role/*"),
+}
+
+func (s *Nomad) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	l.Debug("Start backup access configuration")
+	keyPrefix := path.Join("logical", s.Engine.UUID)
+	if err := s.VaultBackupSingleKey(ctx, keyPrefix, "config/access", "token", "ca_cert", "client_cert", "client_key"); err != nil {
+		return err
+	}
+
+	l.Debug("Start backup lease time")
+	if err := s.VaultBackupSingleKey(ctx, keyPrefix, "config/lease"); err != nil {
+		return err
+	}
+
+	l.Debug("Start backup roles")
+	return s.VaultBackupRoles(ctx, "role")
+}
+
+func (s *Nomad) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	l.Debug("Start restore access configuration")
+	if err := s.VaultRestoreSingleKey(ctx, "config/access"); err != nil {
+		return err
+	}
+
+	l.Debug("Start restore lease configuration")
+	if err := s.VaultRestoreSingleKey(ctx, "config/lease"); err != nil {
+		return err
+	}
+
+	l.Debug("Start restore roles")
+	return s.VaultRestoreRoles(ctx, "role")
+}
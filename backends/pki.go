@@ -10,6 +10,12 @@ type PKI struct {
 	*Object
 }
 
+// pkiPaths covers the whole storage of the mount, PKI is only backed up through sys/raw
+var pkiPaths = Paths{
+	Backup:  RawReads("*"),
+	Restore: RawWrites("*"),
+}
+
 func (s *PKI) Backup(ctx context.Context) error {
 	s.L.With(zap.String("method", "Backup")).Debug("Start backup")
 	keyPrefix := path.Join("logical", s.Engine.UUID)
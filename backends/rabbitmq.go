@@ -0,0 +1,73 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"go.uber.org/zap"
+	"os"
+	"path"
+)
+
+type RabbitMQ struct {
+	*Object
+}
+
+var rabbitMQPaths = Paths{
+	Backup:  Join(RawReads("config/connection"), Reads("config/lease", "roles/*")),
+	Restore: Writes("config/connection", "config/lease", "roles/*"),
+}
+
+func (s *RabbitMQ) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	// connection configuration can not be read through the API
+	if s.Options.RawAccessible {
+		l.Debug("Start backup connection configuration")
+		keyPrefix := path.Join("logical", s.Engine.UUID)
+		if err := s.RawBackupSingleKey(ctx, keyPrefix, "config/connection"); err != nil {
+			return err
+		}
+	}
+
+	l.Debug("Start backup lease time")
+	if err := s.VaultBackupSingleKey(ctx, "", "config/lease"); err != nil {
+		return err
+	}
+
+	l.Debug("Start backup roles")
+	return s.VaultBackupRoles(ctx, "roles")
+}
+
+func (s *RabbitMQ) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	l.Debug("Start restore connection configuration")
+	data, err := s.ReadFileAndB64Decode(ctx, "config/connection")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if os.IsNotExist(err) {
+		l.Warn("No connection configuration found, skip restore connection configuration")
+	} else {
+		payload := map[string]interface{}{}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+		// the target may not reach RabbitMQ during restore
+		payload["verify_connection"] = false
+
+		l.Debug("Write connection configuration to vault")
+		if err := s.VaultWriteOnly(ctx, path.Join(s.Engine.Path, "config/connection"), payload); err != nil {
+			return err
+		}
+	}
+
+	l.Debug("Start restore lease configuration")
+	if err := s.VaultRestoreSingleKey(ctx, "config/lease"); err != nil {
+		return err
+	}
+
+	l.Debug("Start restore roles")
+	return s.VaultRestoreRoles(ctx, "roles")
+}
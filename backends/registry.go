@@ -0,0 +1,146 @@
+package backends
+
+import (
+	"fmt"
+	"github.com/hashicorp/vault-client-go"
+	"sort"
+	"sync"
+)
+
+// Factory returns the engine implementation around the object shared by every engine
+type Factory func(o *Object) Engine
+
+// Capabilities describe what an engine can back up and check
+type Capabilities struct {
+	// RequiresRaw engines can not be backed up without sys/raw, eg: pki
+	RequiresRaw bool
+	// NonRaw engines only use their API, sys/raw is not used even when it is accessible.
+	// Other engines back up more configuration with sys/raw when it is accessible
+	NonRaw bool
+	// Verify engines record what restore writes so that it can be read back, see RestoreVerifier
+	Verify bool
+	// Diff engines record where backed up data comes from, verify --live compares it with Vault
+	Diff bool
+	// Paths are what Backup and Restore use, preflight checks them and policy grants them
+	Paths Paths
+}
+
+// Registration is a registered engine implementation
+type Registration struct {
+	Type         EngineType
+	Factory      Factory
+	Capabilities Capabilities
+}
+
+// Registry maps engine types to their implementation
+type Registry struct {
+	mu      sync.RWMutex
+	engines map[EngineType]Registration
+}
+
+func NewRegistry() *Registry {
+	return &Registry{engines: map[EngineType]Registration{}}
+}
+
+// Register adds an engine implementation, it panics when the type is already registered
+func (r *Registry) Register(et EngineType, factory Factory, capabilities Capabilities) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if factory == nil {
+		panic(fmt.Sprintf("engine type '%v' registered without factory", et))
+	}
+	if _, ok := r.engines[et]; ok {
+		panic(fmt.Sprintf("engine type '%v' is already registered", et))
+	}
+	r.engines[et] = Registration{Type: et, Factory: factory, Capabilities: capabilities}
+}
+
+// Lookup returns the implementation of the engine type
+func (r *Registry) Lookup(et EngineType) (Registration, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reg, ok := r.engines[et]
+	return reg, ok
+}
+
+// Types returns registered engine types, sorted
+func (r *Registry) Types() []EngineType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]EngineType, 0, len(r.engines))
+	for et := range r.engines {
+		types = append(types, et)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// New returns the engine of the registered type mounted at e.Path, backup and restore fail early
+// when the engine requires sys/raw and it is not accessible
+func (r *Registry) New(v *vault.Client, e *SecretEngine, options *Options, et EngineType) (Engine, error) {
+	reg, ok := r.Lookup(et)
+	if !ok {
+		return nil, fmt.Errorf("engine type '%v' at '%v' is not supported", et, e.Path)
+	}
+
+	caps := reg.Capabilities
+	if caps.NonRaw {
+		options.RawAccessible = false
+	}
+	if caps.RequiresRaw && !options.RawAccessible && (options.BackupPath != "" || options.RestorePath != "") {
+		return nil, fmt.Errorf("engine type '%v' at '%v' requires sys/raw access", et, e.Path)
+	}
+
+	o, err := NewObject(v, e, options, string(et))
+	if err != nil {
+		return nil, err
+	}
+	return reg.Factory(o), nil
+}
+
+// engines are the secrets engines implementations
+var engines = NewRegistry()
+
+// Register adds a secrets engine implementation, engines mounted with this type are backed up and
+// restored with it. It panics when the type is already registered
+func Register(et EngineType, factory Factory, capabilities Capabilities) {
+	engines.Register(et, factory, capabilities)
+}
+
+// Lookup returns the secrets engine implementation of the type
+func Lookup(et EngineType) (Registration, bool) {
+	return engines.Lookup(et)
+}
+
+// Types returns registered secrets engine types
+func Types() []EngineType {
+	return engines.Types()
+}
+
+func init() {
+	api := func(paths Paths) Capabilities {
+		return Capabilities{Verify: true, Diff: true, Paths: paths}
+	}
+
+	Register(ADEngine, func(o *Object) Engine { return &AD{o} }, api(adPaths))
+	Register(AWSEngine, func(o *Object) Engine { return &AWS{o} }, api(awsPaths))
+	Register(AzureEngine, func(o *Object) Engine { return &Azure{o} }, api(azurePaths))
+	Register(ConsulEngine, func(o *Object) Engine { return &Consul{o} }, api(consulPaths))
+	Register(DatabaseEngine, func(o *Object) Engine { return &Database{o} }, api(databasePaths))
+	Register(GCPEngine, func(o *Object) Engine { return &GCP{o} }, api(gcpPaths))
+	Register(IdentityEngine, func(o *Object) Engine { return &Identity{o} }, api(identityPaths))
+	Register(KubernetesEngine, func(o *Object) Engine { return &Kubernetes{o} }, api(kubernetesPaths))
+	Register(LDAPEngine, func(o *Object) Engine { return &LDAP{o} }, api(ldapPaths))
+	Register(NomadEngine, func(o *Object) Engine { return &Nomad{o} }, api(nomadPaths))
+	Register(OpenLDAPEngine, func(o *Object) Engine { return &LDAP{o} }, api(ldapPaths))
+	Register(PKIEngine, func(o *Object) Engine { return &PKI{o} }, Capabilities{RequiresRaw: true, Verify: true, Diff: true, Paths: pkiPaths})
+	Register(RabbitMQEngine, func(o *Object) Engine { return &RabbitMQ{o} }, api(rabbitMQPaths))
+	Register(SSHEngine, func(o *Object) Engine { return &SSH{o} }, api(sshPaths))
+	Register(SecretV1Engine, func(o *Object) Engine { return &SecretV1{o} }, api(secretV1Paths))
+	Register(SecretV2Engine, func(o *Object) Engine { return &SecretV2{o} }, Capabilities{NonRaw: true, Verify: true, Diff: true, Paths: secretV2Paths})
+	Register(TOTPEngine, func(o *Object) Engine { return &TOTP{o} }, api(totpPaths))
+	Register(TransitEngine, func(o *Object) Engine { return &Transit{o} }, Capabilities{NonRaw: true, Verify: true, Paths: transitPaths})
+}
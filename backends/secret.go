@@ -8,12 +8,18 @@ import (
 	"go.uber.org/zap"
 	"os"
 	"path"
+	"sort"
 )
 
 type SecretV1 struct {
 	*Object
 }
 
+var secretV1Paths = Paths{
+	Backup:  Reads("*"),
+	Restore: Writes("*"),
+}
+
 func (s *SecretV1) Backup(ctx context.Context) error {
 	l := s.L.With(zap.String("method", "Backup"))
 
@@ -32,7 +38,10 @@ func (s *SecretV1) Backup(ctx context.Context) error {
 		l.Debug("Backup key", zap.String("path", vp))
 		data, err := s.Vault.Read(ctx, vp)
 		if err != nil {
-			return err
+			if err := s.Skip(p, err); err != nil {
+				return err
+			}
+			continue
 		}
 
 		l.Debug("Marshal data from local file")
@@ -42,6 +51,12 @@ func (s *SecretV1) Backup(ctx context.Context) error {
 		}
 
 		payload[p] = base64.StdEncoding.EncodeToString(bs)
+		s.AddChecksum(Checksum{
+			File:     fmt.Sprintf("file%d.json", count),
+			Key:      p,
+			Encoding: "base64",
+			Source:   SourceVault + vp,
+		}, bs)
 		if len(payload) >= records {
 			l.Debug("Marshal data from map value")
 			content, _ := json.Marshal(payload)
@@ -92,7 +107,14 @@ func (s *SecretV1) Restore(ctx context.Context) error {
 			return err
 		}
 
-		for key, b64value := range entries {
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		err = s.EachKey(keys, func(key string) error {
+			b64value := entries[key]
 			l.Debug("Decode base64 entry value", zap.String("key", key), zap.String("value", b64value))
 			bs, err := base64.StdEncoding.DecodeString(b64value)
 			if err != nil {
@@ -105,13 +127,52 @@ func (s *SecretV1) Restore(ctx context.Context) error {
 				return err
 			}
 
-			vp := path.Join(s.Engine.Path, key)
-			l.Debug("Write data to vault key", zap.String("key", vp))
-			if _, err := s.Vault.Write(ctx, vp, value); err != nil {
-				return err
-			}
+			l.Debug("Write data to vault key", zap.String("key", key))
+			return s.WriteSecret(ctx, key, value)
+		})
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
+
+// WriteSecret writes data at key of the engine
+func (s *SecretV1) WriteSecret(ctx context.Context, key string, data map[string]interface{}) error {
+	return s.VaultWrite(ctx, path.Join(s.Engine.Path, key), data)
+}
+
+// ReadChunks returns the decoded entries of every fileN.json chunk of a kv engine backup
+func ReadChunks(dir string) (map[string][]byte, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[string][]byte{}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		content, err := os.ReadFile(path.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		chunk := map[string]string{}
+		if err := json.Unmarshal(content, &chunk); err != nil {
+			return nil, fmt.Errorf("'%v' is not a chunk: %v", f.Name(), err)
+		}
+
+		for k, v := range chunk {
+			bs, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return nil, err
+			}
+			entries[k] = bs
+		}
+	}
+	return entries, nil
+}
@@ -11,12 +11,18 @@ import (
 	"os"
 	"path"
 	"sort"
+	"strings"
 )
 
 type SecretV2 struct {
 	*Object
 }
 
+var secretV2Paths = Paths{
+	Backup:  Reads("metadata/*", "data/*"),
+	Restore: Writes("metadata/*", "data/*", "destroy/*"),
+}
+
 type SecretV2Metadata struct {
 	Cas                bool                       `json:"cas_required"`
 	MaxVersions        int                        `json:"max_versions"`
@@ -115,7 +121,7 @@ func (s *SecretV2) backupSingleKey(ctx context.Context, key string) ([]byte, err
 // Create a fake destroyed/deleted version
 func (s *SecretV2) writeData(ctx context.Context, key string, data map[string]interface{}) error {
 	s.L.With(zap.String("method", "writeData")).Debug("Write vault data", zap.String("key", key))
-	_, err := s.Vault.Write(ctx, path.Join(s.Engine.Path, "data", key), map[string]interface{}{
+	err := s.VaultWriteOnly(ctx, path.Join(s.Engine.Path, "data", key), map[string]interface{}{
 		"data": data,
 	})
 	if err != nil {
@@ -141,7 +147,7 @@ func (s *SecretV2) readData(ctx context.Context, key string, version int) (map[s
 // Write metadata
 func (s *SecretV2) writeMetaData(ctx context.Context, key string, metadata *SecretV2Metadata) error {
 	s.L.With(zap.String("method", "writeMetaData")).Debug("Write vault metadata", zap.String("key", key))
-	if _, err := s.Vault.Write(ctx, path.Join(s.Engine.Path, "metadata", key), map[string]interface{}{
+	if err := s.VaultWrite(ctx, path.Join(s.Engine.Path, "metadata", key), map[string]interface{}{
 		"max_versions":         metadata.MaxVersions,
 		"cas_required":         metadata.Cas,
 		"delete_version_after": metadata.DeleteVersionAfter,
@@ -160,7 +166,7 @@ func (s *SecretV2) destroyVersions(ctx context.Context, key string, versions []i
 		return nil
 	}
 
-	if _, err := s.Vault.Write(ctx, path.Join(s.Engine.Path, "destroy", key), map[string]interface{}{
+	if err := s.VaultWriteOnly(ctx, path.Join(s.Engine.Path, "destroy", key), map[string]interface{}{
 		"versions": versions,
 	}); err != nil {
 		return err
@@ -195,6 +201,14 @@ func (s *SecretV2) restoreSingleKey(ctx context.Context, key string, data []byte
 		if err := s.writeData(ctx, key, data); err != nil {
 			return err
 		}
+		if len(data) > 0 {
+			s.Expect(Expectation{
+				Path:  path.Join(s.Engine.Path, "data", key),
+				Query: url.Values{"version": {fmt.Sprintf("%d", i)}},
+				Field: "data",
+				Data:  data,
+			})
+		}
 
 		if i == 1 {
 			// update metadata after first version was created
@@ -230,9 +244,18 @@ func (s *SecretV2) Backup(ctx context.Context) error {
 		l.Debug("Start backup key", zap.String("key", p))
 		bs, err := s.backupSingleKey(ctx, p)
 		if err != nil {
-			return err
+			if err := s.Skip(p, err); err != nil {
+				return err
+			}
+			continue
 		}
 		payload[p] = base64.StdEncoding.EncodeToString(bs)
+		s.AddChecksum(Checksum{
+			File:     fmt.Sprintf("file%d.json", count),
+			Key:      p,
+			Encoding: "base64",
+			Source:   SourceKV2 + p,
+		}, bs)
 		if len(payload) == records {
 			content, _ := json.Marshal(payload)
 			of := fmt.Sprintf("file%d.json", count)
@@ -258,6 +281,32 @@ func (s *SecretV2) Backup(ctx context.Context) error {
 	return nil
 }
 
+// WriteSecret writes data as a new version of key
+func (s *SecretV2) WriteSecret(ctx context.Context, key string, data map[string]interface{}) error {
+	if err := s.writeData(ctx, key, data); err != nil {
+		return err
+	}
+
+	s.Expect(Expectation{Path: path.Join(s.Engine.Path, "data", key), Field: "data", Data: data})
+	return nil
+}
+
+// LiveChecksum reads all versions of a key again, other sources are handled by Object
+func (s *SecretV2) LiveChecksum(ctx context.Context, c Checksum) (string, error) {
+	if !strings.HasPrefix(c.Source, SourceKV2) {
+		return s.Object.LiveChecksum(ctx, c)
+	}
+
+	bs, err := s.backupSingleKey(ctx, strings.TrimPrefix(c.Source, SourceKV2))
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "404") {
+			return "", nil
+		}
+		return "", err
+	}
+	return Sum(bs), nil
+}
+
 func (s *SecretV2) Restore(ctx context.Context) error {
 	l := s.L.With(zap.String("method", "Backup"))
 
@@ -278,15 +327,22 @@ func (s *SecretV2) Restore(ctx context.Context) error {
 			return err
 		}
 
-		for k, v := range entries {
-			bs, err := base64.StdEncoding.DecodeString(v)
+		keys := make([]string, 0, len(entries))
+		for k := range entries {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		err = s.EachKey(keys, func(k string) error {
+			bs, err := base64.StdEncoding.DecodeString(entries[k])
 			if err != nil {
 				return err
 			}
 			l.Debug("Run restore process", zap.String("key", k))
-			if err := s.restoreSingleKey(ctx, k, bs); err != nil {
-				return err
-			}
+			return s.restoreSingleKey(ctx, k, bs)
+		})
+		if err != nil {
+			return err
 		}
 
 	}
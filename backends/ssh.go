@@ -12,6 +12,11 @@ type SSH struct {
 	*Object
 }
 
+var sshPaths = Paths{
+	Backup:  Join(RawReads("config/*"), Reads("roles/*")),
+	Restore: Writes("config/ca", "roles/*"),
+}
+
 func (s *SSH) Backup(ctx context.Context) error {
 	l := s.L.With(zap.String("method", "Backup"))
 
@@ -60,7 +65,7 @@ func (s *SSH) Restore(ctx context.Context) error {
 			return err
 		}
 
-		if _, err := s.Vault.Write(ctx, path.Join(s.Engine.Path, "config/ca"), map[string]interface{}{
+		if err := s.VaultWrite(ctx, path.Join(s.Engine.Path, "config/ca"), map[string]interface{}{
 			"private_key":          CAPrivateKey["key"],
 			"public_key":           CAPublicKey["key"],
 			"generate_signing_key": false,
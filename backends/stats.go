@@ -0,0 +1,30 @@
+package backends
+
+// Stats counts what an engine backed up or restored
+type Stats struct {
+	Keys  int
+	Bytes int64
+}
+
+// Stats returns keys and bytes written by backup, keys are secrets of kv chunks and other files,
+// restore counts writes sent to Vault
+func (o *Object) Stats() Stats {
+	if o.Options.RestorePath != "" {
+		return Stats{Keys: int(o.writes.Load())}
+	}
+
+	chunks := map[string]bool{}
+	for _, c := range o.checksums {
+		if c.Key != "" {
+			chunks[c.File] = true
+		}
+	}
+
+	keys := 0
+	for _, c := range o.checksums {
+		if c.Key != "" || !chunks[c.File] {
+			keys++
+		}
+	}
+	return Stats{Keys: keys, Bytes: o.bytes.Load()}
+}
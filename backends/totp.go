@@ -11,6 +11,11 @@ type TOTP struct {
 	*Object
 }
 
+var totpPaths = Paths{
+	Backup:  RawReads("key/*"),
+	Restore: Writes("keys/*"),
+}
+
 type TOTPKey struct {
 	Exported    bool   `json:"exported"`
 	Url         string `json:"url"`
@@ -76,7 +81,7 @@ func (t *TOTP) Restore(ctx context.Context) error {
 			return err
 		}
 
-		if _, err := t.Vault.Write(ctx, path.Join(t.Engine.Path, "keys", path.Base(p)), payload); err != nil {
+		if err := t.VaultWrite(ctx, path.Join(t.Engine.Path, "keys", path.Base(p)), payload); err != nil {
 			return err
 		}
 
@@ -3,14 +3,43 @@ package backends
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"go.uber.org/zap"
+	"os"
 	"path"
+	"sort"
+	"strconv"
 )
 
 type Transit struct {
 	*Object
 }
 
+// transitPaths allow plaintext backup of keys, their config is updated before the backup
+var transitPaths = Paths{
+	Backup:  Join(Reads("keys/*", "backup/*", "export/*", "cache-config"), Writes("keys/+/config")),
+	Restore: Join(Reads("wrapping_key"), Writes("restore/*", "keys/*", "cache-config")),
+}
+
+// TransitKeyConfig is the part of keys/<name> which is not carried by the backup blob
+// or is overwritten while taking the backup
+type TransitKeyConfig struct {
+	Type                 string `json:"type"`
+	Derived              bool   `json:"derived"`
+	MinDecryptionVersion int    `json:"min_decryption_version"`
+	MinEncryptionVersion int    `json:"min_encryption_version"`
+	DeletionAllowed      bool   `json:"deletion_allowed"`
+	AutoRotatePeriod     int    `json:"auto_rotate_period"`
+	Exportable           bool   `json:"exportable"`
+	AllowPlaintextBackup bool   `json:"allow_plaintext_backup"`
+}
+
+// TransitExport is the key material of every version exported from export/<type>/<name>
+type TransitExport struct {
+	ExportType string            `json:"export_type"`
+	Keys       map[string]string `json:"keys"`
+}
+
 func (t *Transit) Backup(ctx context.Context) error {
 	l := t.L.With(zap.String("method", "Backup"))
 
@@ -22,6 +51,33 @@ func (t *Transit) Backup(ctx context.Context) error {
 	}
 
 	for _, p := range paths {
+		// key config must be captured before exportable and allow_plaintext_backup are forced
+		kp := path.Join(t.Engine.Path, p)
+		l.Debug("Read key configuration", zap.String("path", kp))
+		config, err := t.Vault.Read(ctx, kp)
+		if err != nil {
+			return err
+		}
+
+		l.Debug("Write key configuration to local file")
+		if err := t.WriteVaultResponse(ctx, path.Join("config", path.Base(p)), config.Data); err != nil {
+			return err
+		}
+
+		var kc TransitKeyConfig
+		if err := t.decodeKeyConfig(config.Data, &kc); err != nil {
+			return err
+		}
+
+		// exportable keys without plaintext backup are exported and imported on restore,
+		// so plaintext backup does not need to be enabled
+		if kc.Exportable && !kc.AllowPlaintextBackup {
+			if err := t.exportKey(ctx, path.Base(p), kc.Type); err != nil {
+				return err
+			}
+			continue
+		}
+
 		vp := path.Join(t.Engine.Path, p, "config")
 		l.Debug("Enable exportable for key", zap.String("path", vp))
 		if _, err := t.Vault.Write(ctx, vp, map[string]interface{}{
@@ -45,7 +101,13 @@ func (t *Transit) Backup(ctx context.Context) error {
 		}
 	}
 
-	return nil
+	l.Debug("Start backup cache configuration")
+	data, err := t.Vault.Read(ctx, path.Join(t.Engine.Path, "cache-config"))
+	if err != nil {
+		return err
+	}
+
+	return t.WriteVaultResponse(ctx, "cache-config", data.Data)
 }
 
 func (t *Transit) Restore(ctx context.Context) error {
@@ -53,7 +115,7 @@ func (t *Transit) Restore(ctx context.Context) error {
 
 	l.Debug("Start restore keys")
 	paths, err := t.LocalWalk(ctx, t.Options.RestorePath, "keys")
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
@@ -72,10 +134,274 @@ func (t *Transit) Restore(ctx context.Context) error {
 
 		vp := path.Join(t.Engine.Path, "restore", path.Base(p))
 		l.Debug("Write data to vault", zap.String("path", vp))
-		if _, err := t.Vault.Write(ctx, vp, payload); err != nil {
+		if err := t.VaultWriteOnly(ctx, vp, payload); err != nil {
+			return err
+		}
+	}
+
+	imported, err := t.importKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := t.restoreKeysConfig(ctx, imported); err != nil {
+		return err
+	}
+
+	l.Debug("Start restore cache configuration")
+	data, err := t.ReadFileAndB64Decode(ctx, "cache-config")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if os.IsNotExist(err) {
+		l.Warn("No cache configuration found, skip restore cache configuration")
+		return nil
+	}
+
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	l.Debug("Write cache configuration to vault")
+	if err := t.VaultWrite(ctx, path.Join(t.Engine.Path, "cache-config"), map[string]interface{}{
+		"size": payload["size"],
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// restoreKeysConfig reapplies the settings captured from keys/<name> at backup time, minimum versions
+// of imported keys are shifted by the number of versions they were renumbered by
+func (t *Transit) restoreKeysConfig(ctx context.Context, imported map[string]int) error {
+	l := t.L.With(zap.String("method", "restoreKeysConfig"))
+
+	l.Debug("Start restore keys configuration")
+	paths, err := t.LocalWalk(ctx, t.Options.RestorePath, "config")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if os.IsNotExist(err) {
+		l.Warn("No config directory found, skip restore keys configuration")
+		return nil
+	}
+
+	for _, p := range paths {
+		name := path.Base(p)
+		offset, ok := imported[name]
+		// exported keys which were refused do not exist
+		if _, err := os.Stat(path.Join(t.Options.RestorePath, "export", name)); err == nil && !ok {
+			l.Warn("Key was not imported, skip restore key configuration", zap.String("key", name))
+			continue
+		}
+
+		l.Debug("Read and decode key configuration", zap.String("path", p))
+		data, err := t.ReadFileAndB64Decode(ctx, p)
+		if err != nil {
+			return err
+		}
+
+		var config TransitKeyConfig
+		if err := json.Unmarshal(data, &config); err != nil {
+			return err
+		}
+
+		payload := map[string]interface{}{
+			"min_decryption_version": config.MinDecryptionVersion,
+			"min_encryption_version": config.MinEncryptionVersion,
+			"deletion_allowed":       config.DeletionAllowed,
+			"auto_rotate_period":     config.AutoRotatePeriod,
+		}
+
+		// imported keys are created with their original exportable and allow_plaintext_backup
+		if ok {
+			if offset > 0 {
+				payload["min_decryption_version"] = renumberVersion(config.MinDecryptionVersion, offset)
+				payload["min_encryption_version"] = renumberVersion(config.MinEncryptionVersion, offset)
+				l.Warn("Key versions were renumbered, minimum versions are shifted", zap.String("key", name),
+					zap.Int("offset", offset), zap.Any("min_decryption_version", payload["min_decryption_version"]))
+			}
+
+			l.Debug("Write key configuration to vault", zap.String("key", name))
+			if err := t.VaultWriteOnly(ctx, path.Join(t.Engine.Path, "keys", name, "config"), payload); err != nil {
+				return err
+			}
+			t.Expect(Expectation{Path: path.Join(t.Engine.Path, "keys", name), Data: payload})
+			continue
+		}
+
+		// Vault only allows exportable and allow_plaintext_backup to go from false to true,
+		// the restored key has both enabled because the backup blob was taken with them on
+		if config.Exportable {
+			payload["exportable"] = true
+		} else {
+			l.Warn("Key was not exportable originally, Vault does not allow disabling it", zap.String("key", name))
+		}
+		if config.AllowPlaintextBackup {
+			payload["allow_plaintext_backup"] = true
+		} else {
+			l.Warn("Key did not allow plaintext backup originally, Vault does not allow disabling it", zap.String("key", name))
+		}
+
+		vp := path.Join(t.Engine.Path, "keys", name, "config")
+		l.Debug("Write key configuration to vault", zap.String("path", vp))
+		if err := t.VaultWriteOnly(ctx, vp, payload); err != nil {
 			return err
 		}
+		t.Expect(Expectation{Path: path.Join(t.Engine.Path, "keys", name), Data: payload})
 	}
 
 	return nil
 }
+
+func (t *Transit) decodeKeyConfig(data map[string]interface{}, config *TransitKeyConfig) error {
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, config)
+}
+
+// exportKey backs up the key material of all versions of an exportable key
+func (t *Transit) exportKey(ctx context.Context, name, keyType string) error {
+	l := t.L.With(zap.String("method", "exportKey"))
+
+	exportType := transitExportType(keyType)
+	vp := path.Join(t.Engine.Path, "export", exportType, name)
+	l.Debug("Read export key endpoint", zap.String("path", vp))
+	data, err := t.Vault.Read(ctx, vp)
+	if err != nil {
+		return err
+	}
+
+	l.Debug("Write exported key to local file")
+	return t.WriteVaultResponse(ctx, path.Join("export", name), map[string]interface{}{
+		"export_type": exportType,
+		"keys":        data.Data["keys"],
+	})
+}
+
+// importKeys restores exported keys through keys/<name>/import using the target wrapping key,
+// it returns imported keys with the number their versions were renumbered by. Keys whose oldest
+// versions were trimmed are refused unless Options.TransitRenumberVersions is set
+func (t *Transit) importKeys(ctx context.Context) (map[string]int, error) {
+	l := t.L.With(zap.String("method", "importKeys"))
+	imported := map[string]int{}
+
+	l.Debug("Start import exported keys")
+	paths, err := t.LocalWalk(ctx, t.Options.RestorePath, "export")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(paths) == 0 {
+		return imported, nil
+	}
+
+	l.Debug("Read wrapping key")
+	wk, err := t.Vault.Read(ctx, path.Join(t.Engine.Path, "wrapping_key"))
+	if err != nil {
+		return nil, err
+	}
+	wrappingKey, _ := wk.Data["public_key"].(string)
+
+	for _, p := range paths {
+		name := path.Base(p)
+		l.Debug("Read and decode exported key", zap.String("path", p))
+		data, err := t.ReadFileAndB64Decode(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+
+		var export TransitExport
+		if err := json.Unmarshal(data, &export); err != nil {
+			return nil, err
+		}
+
+		data, err = t.ReadFileAndB64Decode(ctx, path.Join("config", name))
+		if err != nil {
+			return nil, err
+		}
+
+		var config TransitKeyConfig
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, err
+		}
+
+		var versions []int
+		for v := range export.Keys {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, err
+			}
+			versions = append(versions, i)
+		}
+		sort.Ints(versions)
+
+		offset := 0
+		if len(versions) > 0 && versions[0] != 1 {
+			if !t.Options.TransitRenumberVersions {
+				err := fmt.Errorf("versions before %d were trimmed, import would renumber them from 1 and ciphertexts "+
+					"would not decrypt, --transit-renumber-versions imports it anyway", versions[0])
+				if err := t.Skip(name, err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			offset = versions[0] - 1
+			l.Warn("Key versions before the oldest exported one are gone, imported versions are renumbered from 1",
+				zap.String("key", name), zap.Int("oldest", versions[0]))
+		}
+
+		for i, v := range versions {
+			material, err := transitKeyMaterial(config.Type, export.Keys[strconv.Itoa(v)])
+			if err != nil {
+				return nil, err
+			}
+
+			ciphertext, err := transitWrapKey(wrappingKey, material)
+			if err != nil {
+				return nil, err
+			}
+
+			payload := map[string]interface{}{
+				"ciphertext":    ciphertext,
+				"hash_function": "SHA256",
+			}
+
+			vp := path.Join(t.Engine.Path, "keys", name, "import_version")
+			if i == 0 {
+				vp = path.Join(t.Engine.Path, "keys", name, "import")
+				payload["type"] = config.Type
+				payload["derived"] = config.Derived
+				payload["exportable"] = config.Exportable
+				payload["allow_plaintext_backup"] = config.AllowPlaintextBackup
+				payload["allow_rotation"] = true
+			}
+
+			l.Debug("Import key version", zap.String("path", vp), zap.Int("version", v))
+			if err := t.VaultWriteOnly(ctx, vp, payload); err != nil {
+				return nil, err
+			}
+		}
+
+		imported[name] = offset
+	}
+
+	return imported, nil
+}
+
+// renumberVersion returns the version of a key renumbered by offset, 0 is the latest version and stays 0
+func renumberVersion(version, offset int) int {
+	if version == 0 {
+		return 0
+	}
+	if version -= offset; version < 1 {
+		return 1
+	}
+	return version
+}
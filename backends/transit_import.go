@@ -0,0 +1,151 @@
+package backends
+
+import (
+	"crypto/aes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// transitExportType returns the export/<type>/<name> endpoint type holding the key material
+func transitExportType(keyType string) string {
+	switch {
+	case keyType == "hmac":
+		return "hmac-key"
+	case strings.HasPrefix(keyType, "ecdsa-"), keyType == "ed25519":
+		return "signing-key"
+	default:
+		return "encryption-key"
+	}
+}
+
+// transitKeyMaterial converts an exported key to the format accepted by keys/<name>/import:
+// raw bytes for symmetric keys and PKCS#8 DER for asymmetric keys
+func transitKeyMaterial(keyType, exported string) ([]byte, error) {
+	switch {
+	case keyType == "ed25519":
+		bs, err := base64.StdEncoding.DecodeString(exported)
+		if err != nil {
+			return nil, err
+		}
+		if len(bs) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("unexpected ed25519 key size %d", len(bs))
+		}
+		return x509.MarshalPKCS8PrivateKey(ed25519.PrivateKey(bs))
+	case strings.HasPrefix(keyType, "rsa-"), strings.HasPrefix(keyType, "ecdsa-"):
+		block, _ := pem.Decode([]byte(exported))
+		if block == nil {
+			return nil, errors.New("exported key is not PEM encoded")
+		}
+
+		var key interface{}
+		var err error
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		default:
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch key.(type) {
+		case *rsa.PrivateKey, *ecdsa.PrivateKey:
+		default:
+			return nil, fmt.Errorf("unexpected private key for key type %v", keyType)
+		}
+		return x509.MarshalPKCS8PrivateKey(key)
+	default:
+		return base64.StdEncoding.DecodeString(exported)
+	}
+}
+
+// transitWrapKey wraps the key material for BYOK import: an ephemeral AES-256 key is encrypted with
+// the target wrapping key using RSA-OAEP (SHA-256) and the key material is wrapped with AES-KWP
+func transitWrapKey(wrappingKeyPEM string, material []byte) (string, error) {
+	block, _ := pem.Decode([]byte(wrappingKeyPEM))
+	if block == nil {
+		return "", errors.New("wrapping key is not PEM encoded")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return "", errors.New("wrapping key is not an RSA public key")
+	}
+
+	ephemeral := make([]byte, 32)
+	if _, err := rand.Read(ephemeral); err != nil {
+		return "", err
+	}
+
+	wrappedAES, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, rsaPub, ephemeral, nil)
+	if err != nil {
+		return "", err
+	}
+
+	wrappedKey, err := aesKeyWrapWithPadding(ephemeral, material)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(append(wrappedAES, wrappedKey...)), nil
+}
+
+// aesKeyWrapWithPadding implements AES Key Wrap with Padding (RFC 5649)
+func aesKeyWrapWithPadding(kek, plaintext []byte) ([]byte, error) {
+	if len(plaintext) == 0 {
+		return nil, errors.New("nothing to wrap")
+	}
+
+	cipher, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	// alternative initial value: 0xA65959A6 followed by the 32-bit plaintext length
+	var a [8]byte
+	binary.BigEndian.PutUint32(a[:4], 0xA65959A6)
+	binary.BigEndian.PutUint32(a[4:], uint32(len(plaintext)))
+
+	padded := make([]byte, (len(plaintext)+7)/8*8)
+	copy(padded, plaintext)
+	n := len(padded) / 8
+
+	block := make([]byte, aes.BlockSize)
+	if n == 1 {
+		copy(block[:8], a[:])
+		copy(block[8:], padded)
+		cipher.Encrypt(block, block)
+		return block, nil
+	}
+
+	// RFC 3394 wrapping process using the alternative initial value
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			copy(block[:8], a[:])
+			copy(block[8:], padded[i*8:(i+1)*8])
+			cipher.Encrypt(block, block)
+
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(a[:], binary.BigEndian.Uint64(block[:8])^t)
+			copy(padded[i*8:(i+1)*8], block[8:])
+		}
+	}
+
+	return append(a[:], padded...), nil
+}
@@ -2,10 +2,10 @@ package backends
 
 import (
 	"context"
+	"fmt"
 	"github.com/hashicorp/vault-client-go"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"log"
 	"os"
 	"path"
 	"strings"
@@ -37,6 +37,21 @@ type Options struct {
 	RestorePath    string
 	LogLevel       string
 	RawAccessible  bool
+	// BackupSecretIDs backs up AppRole secret-id accessors metadata, secret-ids themselves can not be restored
+	BackupSecretIDs bool
+	// AuditRewrites replaces the prefix of audit devices file_path or address on restore
+	AuditRewrites map[string]string
+	// PathRemap replaces mount paths referenced by restored configuration, eg: quota paths
+	PathRemap map[string]string
+	// TransitRenumberVersions imports exported transit keys whose oldest versions were trimmed, their
+	// versions are renumbered from 1 and ciphertexts of the original versions do not decrypt anymore
+	TransitRenumberVersions bool
+	// VerifyRestore reads back restored keys and reports mismatches with the backup
+	VerifyRestore bool
+	// ContinueOnError skips keys which fail instead of stopping the engine, see Skip
+	ContinueOnError bool
+	// Logger is the parent of engine loggers, NewLogger(LogLevel) when nil
+	Logger *zap.Logger
 }
 
 type Mode string
@@ -46,22 +61,68 @@ type Engine interface {
 	Restore(context.Context) error
 }
 
+// ChecksumIndexer is implemented by engines which record checksums while backing up
+type ChecksumIndexer interface {
+	WriteChecksumIndex() error
+}
+
+// RestoreVerifier is implemented by engines which can read back what was restored
+type RestoreVerifier interface {
+	VerifyRestore(context.Context) ([]Mismatch, error)
+}
+
+// SecretWriter is implemented by kv engines, it writes the data of one key the way restore does
+type SecretWriter interface {
+	WriteSecret(ctx context.Context, key string, data map[string]interface{}) error
+}
+
+// LiveChecksummer is implemented by engines which can read a backed up secret again from Vault
+type LiveChecksummer interface {
+	LiveChecksum(context.Context, Checksum) (string, error)
+}
+
+// StatsReporter is implemented by engines reporting keys and bytes of their last backup or restore
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// KeyErrorReporter is implemented by engines which skip failed keys when errors do not stop them
+type KeyErrorReporter interface {
+	KeyErrors() []KeyError
+}
+
 type EngineType string
 
 const (
-	ADEngine       EngineType = "ad"
-	AWSEngine      EngineType = "aws"
-	DatabaseEngine EngineType = "database"
-	PKIEngine      EngineType = "pki"
-	RawEngine      EngineType = "raw"
-	SSHEngine      EngineType = "ssh"
-	SecretV1Engine EngineType = "kv"
-	SecretV2Engine EngineType = "kv2"
-	TOTPEngine     EngineType = "totp"
-	TransitEngine  EngineType = "transit"
+	ADEngine         EngineType = "ad"
+	AWSEngine        EngineType = "aws"
+	AzureEngine      EngineType = "azure"
+	ConsulEngine     EngineType = "consul"
+	DatabaseEngine   EngineType = "database"
+	GCPEngine        EngineType = "gcp"
+	IdentityEngine   EngineType = "identity"
+	KubernetesEngine EngineType = "kubernetes"
+	LDAPEngine       EngineType = "ldap"
+	NomadEngine      EngineType = "nomad"
+	OpenLDAPEngine   EngineType = "openldap"
+	PKIEngine        EngineType = "pki"
+	RabbitMQEngine   EngineType = "rabbitmq"
+	RawEngine        EngineType = "raw"
+	SSHEngine        EngineType = "ssh"
+	SecretV1Engine   EngineType = "kv"
+	SecretV2Engine   EngineType = "kv2"
+	TOTPEngine       EngineType = "totp"
+	TransitEngine    EngineType = "transit"
 )
 
-func getLogger(level string) *zap.Logger {
+// Supported reports whether the engine type is registered
+func Supported(et EngineType) bool {
+	_, ok := Lookup(et)
+	return ok
+}
+
+// NewLogger returns the console logger of the tool at the given level
+func NewLogger(level string) *zap.Logger {
 	config := zap.NewProductionConfig()
 	config.Encoding = "console"
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
@@ -71,14 +132,16 @@ func getLogger(level string) *zap.Logger {
 	return logger
 }
 
-func NewSecretEngine(v *vault.Client, e *SecretEngine, options *Options, et EngineType) Engine {
+// NewObject prepares the backup directory, validates the restore path and creates the logger
+// shared by every engine implementation
+func NewObject(v *vault.Client, e *SecretEngine, options *Options, engineType string) (*Object, error) {
 
 	// backup mode
 	if options.BackupPath != "" {
-		options.BackupPath = path.Join(options.BackupPath, e.Path+"."+string(et))
+		options.BackupPath = path.Join(options.BackupPath, e.Path+"."+engineType)
 
 		if err := os.MkdirAll(options.BackupPath, 0755); err != nil {
-			log.Fatalln(err)
+			return nil, err
 		}
 	}
 
@@ -90,47 +153,29 @@ func NewSecretEngine(v *vault.Client, e *SecretEngine, options *Options, et Engi
 			ret = strings.TrimSuffix(ret, "-r")
 		}
 
-		if ret != string(et) {
-			log.Fatalln("Restore path does not match engine type")
+		if ret != engineType {
+			return nil, fmt.Errorf("restore path '%v' does not match engine type '%v'", options.RestorePath, engineType)
 		}
 	}
 
-	logger := getLogger(options.LogLevel)
+	logger := options.Logger
+	if logger == nil {
+		logger = NewLogger(options.LogLevel)
+	}
 
 	o := &Object{
 		Vault:   v,
 		Engine:  e,
 		Options: options,
-		L:       logger.With(zap.String("engine-path", e.Path), zap.String("engine-type", string(et))),
+		L:       logger.With(zap.String("engine-path", e.Path), zap.String("engine-type", engineType)),
 	}
 
 	defer o.L.Sync()
 
-	if et == SecretV2Engine || et == TransitEngine {
-		options.RawAccessible = false
-	}
+	return o, nil
+}
 
-	switch et {
-	case RawEngine:
-		return nil
-	case SSHEngine:
-		return &SSH{o}
-	case TOTPEngine:
-		return &TOTP{o}
-	case ADEngine:
-		return &AD{o}
-	case PKIEngine:
-		return &PKI{o}
-	case DatabaseEngine:
-		return &Database{o}
-	case SecretV1Engine:
-		return &SecretV1{o}
-	case SecretV2Engine:
-		return &SecretV2{o}
-	case AWSEngine:
-		return &AWS{o}
-	case TransitEngine:
-		return &Transit{o}
-	}
-	return nil
+// NewSecretEngine returns the engine of the type mounted at e.Path
+func NewSecretEngine(v *vault.Client, e *SecretEngine, options *Options, et EngineType) (Engine, error) {
+	return engines.New(v, e, options, et)
 }
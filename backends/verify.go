@@ -0,0 +1,253 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/vault-client-go"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expectation is what a restored key must read back as
+type Expectation struct {
+	// Path is read back after restore, it may differ from the written path, eg: transit keys/<name>/config
+	Path string
+	// Query selects what is read, eg: the version of a kv2 key
+	Query url.Values
+	// Field of the response holding the data, eg: data of a kv2 version
+	Field string
+	Data  map[string]interface{}
+	// Raw compares the storage entry at Path with Value
+	Raw   bool
+	Value string
+}
+
+// Mismatch is a restored key or field which does not read back as it was backed up
+type Mismatch struct {
+	Path     string
+	Field    string
+	Expected interface{}
+	Actual   interface{}
+	Missing  bool
+}
+
+// String does not include values, they are secrets
+func (m Mismatch) String() string {
+	if m.Missing {
+		return fmt.Sprintf("'%v' not found", m.Path)
+	}
+	if m.Field == "" {
+		return fmt.Sprintf("'%v' does not match the backup", m.Path)
+	}
+	return fmt.Sprintf("'%v' field '%v' does not match the backup", m.Path, m.Field)
+}
+
+// Expect records a key to read back after restore when restore verification is enabled
+func (o *Object) Expect(e Expectation) {
+	if !o.Options.VerifyRestore {
+		return
+	}
+	o.expectations = append(o.expectations, e)
+}
+
+// VaultWrite writes payload and expects it to read back from the same path
+func (o *Object) VaultWrite(ctx context.Context, vp string, payload map[string]interface{}) error {
+	if err := o.VaultWriteOnly(ctx, vp, payload); err != nil {
+		return err
+	}
+
+	o.Expect(Expectation{Path: vp, Data: payload})
+	return nil
+}
+
+// VaultWriteOnly writes payload without expecting it to read back, writes are counted in restore stats
+func (o *Object) VaultWriteOnly(ctx context.Context, vp string, payload map[string]interface{}) error {
+	if _, err := o.Vault.Write(ctx, vp, payload); err != nil {
+		return err
+	}
+
+	o.writes.Add(1)
+	return nil
+}
+
+// VerifyRestore reads back every expected key, fields Vault does not return, like passwords, are skipped
+func (o *Object) VerifyRestore(ctx context.Context) ([]Mismatch, error) {
+	var mismatches []Mismatch
+
+	for _, e := range o.expectations {
+		if e.Raw {
+			rdata, err := o.Vault.System.RawRead(ctx, e.Path)
+			if err != nil {
+				if strings.Contains(err.Error(), "being decompressed is empty") || vault.IsErrorStatus(err, 404) {
+					mismatches = append(mismatches, Mismatch{Path: SourceRaw + e.Path, Missing: true})
+					continue
+				}
+				return nil, err
+			}
+			if rdata.Data.Value != e.Value {
+				mismatches = append(mismatches, Mismatch{Path: SourceRaw + e.Path, Expected: e.Value, Actual: rdata.Data.Value})
+			}
+			continue
+		}
+
+		var options []vault.RequestOption
+		if e.Query != nil {
+			options = append(options, vault.WithQueryParameters(e.Query))
+		}
+
+		resp, err := o.Vault.Read(ctx, e.Path, options...)
+		if err != nil && !vault.IsErrorStatus(err, 404) {
+			return nil, err
+		}
+
+		var actual map[string]interface{}
+		if resp != nil {
+			actual = resp.Data
+		}
+		if e.Field != "" {
+			actual, _ = actual[e.Field].(map[string]interface{})
+		}
+		if actual == nil {
+			mismatches = append(mismatches, Mismatch{Path: e.Path, Missing: true})
+			continue
+		}
+
+		expected := normalize(e.Data)
+		actual = normalize(actual)
+
+		var fields []string
+		for field := range expected {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		for _, field := range fields {
+			// write-only fields are not returned
+			value, ok := actual[field]
+			if !ok {
+				continue
+			}
+			if !matches(expected[field], value) {
+				mismatches = append(mismatches, Mismatch{Path: e.Path, Field: field, Expected: expected[field], Actual: value})
+			}
+		}
+	}
+
+	return mismatches, nil
+}
+
+// normalize turns typed values, eg: []string, into what JSON decoding returns
+func normalize(data map[string]interface{}) map[string]interface{} {
+	output := map[string]interface{}{}
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return data
+	}
+	if err := json.Unmarshal(bs, &output); err != nil {
+		return data
+	}
+	return output
+}
+
+// matches compares a written value with what Vault returns, Vault may return durations in seconds,
+// comma separated strings as lists or JSON strings as lists and objects
+func matches(expected, actual interface{}) bool {
+	if expected == nil || reflect.DeepEqual(expected, actual) {
+		return true
+	}
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range e {
+			if av, ok := a[k]; ok && !matches(v, av) {
+				return false
+			}
+		}
+		return true
+
+	case []interface{}:
+		if a, ok := actual.(string); ok {
+			return matchesList(e, splitList(a))
+		}
+		a, ok := actual.([]interface{})
+		return ok && matchesList(e, a)
+
+	case string:
+		switch a := actual.(type) {
+		case []interface{}:
+			var v []interface{}
+			if json.Unmarshal([]byte(e), &v) == nil {
+				return matchesList(v, a)
+			}
+			return matchesList(splitList(e), a)
+		case map[string]interface{}:
+			var v interface{}
+			return json.Unmarshal([]byte(e), &v) == nil && matches(v, a)
+		}
+	}
+
+	if es, ok := seconds(expected); ok {
+		if as, ok := seconds(actual); ok {
+			return es == as
+		}
+	}
+
+	return fmt.Sprint(expected) == fmt.Sprint(actual)
+}
+
+// matchesList compares lists regardless of order, Vault sorts some of them
+func matchesList(expected, actual []interface{}) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+
+	used := make([]bool, len(actual))
+	for _, e := range expected {
+		found := false
+		for i, a := range actual {
+			if !used[i] && matches(e, a) {
+				used[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func splitList(value string) []interface{} {
+	var output []interface{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			output = append(output, v)
+		}
+	}
+	return output
+}
+
+// seconds returns numbers and durations as seconds
+func seconds(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, true
+		}
+		if d, err := time.ParseDuration(v); err == nil {
+			return d.Seconds(), true
+		}
+	}
+	return 0, false
+}
@@ -1,25 +1,173 @@
 package main
 
-import "github.com/urfave/cli/v2"
+import (
+	"github.com/urfave/cli/v2"
+	"github.com/zduymz/hs-vault/transfer"
+)
 
 const (
-	FlagPath      = "path"
-	FlagDest      = "dest"
-	FlagSource    = "source"
-	FlagCompress  = "compress"
-	FlagB64Encode = "b64encode"
-	FlagLogLevel  = "log-level"
-	FlagNamespace = "namespace"
-	FlagUseRaw    = "raw"
+	FlagPath         = "path"
+	FlagDest         = "dest"
+	FlagTimestamped  = "timestamped"
+	FlagSource       = "source"
+	FlagCompress     = "compress"
+	FlagB64Encode    = "b64encode"
+	FlagLogLevel     = "log-level"
+	FlagNamespace    = "namespace"
+	FlagUseRaw       = "raw"
+	FlagSecretIDs    = "approle-secret-ids"
+	FlagAuditRewrite = "audit-rewrite"
+	FlagRecursive    = "recursive-namespaces"
+	FlagRemapPath    = "remap-path"
+	FlagRenumber     = "transit-renumber-versions"
+	FlagLive         = "live"
+	FlagVerify       = "verify"
+	FlagKey          = "key"
+	FlagVersion      = "version"
+	FlagFormat       = "format"
+	FlagShowSecrets  = "show-secrets"
+	FlagFilter       = "filter"
+	FlagOut          = "out"
+	FlagFile         = "file"
+	FlagPathTemplate = "path-template"
+
+	FlagConfig            = "config"
+	FlagJob               = "job"
+	FlagAddress           = "address"
+	FlagCACert            = "ca-cert"
+	FlagSkipVerify        = "tls-skip-verify"
+	FlagInclude           = "include"
+	FlagExclude           = "exclude"
+	FlagConcurrency       = "concurrency"
+	FlagKeep              = "keep"
+	FlagEncryptionKeyFile = "encryption-key-file"
+	FlagEncryptionKeyEnv  = "encryption-key-env"
+
+	FlagKeepDaily   = "keep-daily"
+	FlagKeepWeekly  = "keep-weekly"
+	FlagKeepMonthly = "keep-monthly"
+	FlagListen      = "listen"
+	FlagReport      = "report"
+	FlagMetricsFile = "metrics-file"
+
+	FlagContinueOnError = "continue-on-error"
+	FlagSkipPreflight   = "skip-preflight"
+	FlagOperation       = "operation"
+
+	FlagAuthMethod   = "auth-method"
+	FlagAuthMount    = "auth-mount"
+	FlagTokenFile    = "token-file"
+	FlagRoleID       = "role-id"
+	FlagRoleIDFile   = "role-id-file"
+	FlagSecretID     = "secret-id"
+	FlagSecretIDFile = "secret-id-file"
+	FlagAuthRole     = "auth-role"
+	FlagJWTFile      = "jwt-file"
+	FlagUsername     = "username"
+	FlagPassword     = "password"
+	FlagPasswordFile = "password-file"
 )
 
+// connectionFlags select the Vault server and how to log in, they take precedence over VAULT_ADDR,
+// VAULT_CACERT and VAULT_SKIP_VERIFY
+func connectionFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  FlagAddress,
+			Usage: "Vault address",
+		},
+		&cli.StringFlag{
+			Name:  FlagCACert,
+			Usage: "CA certificate file to verify the Vault server",
+		},
+		&cli.BoolFlag{
+			Name:  FlagSkipVerify,
+			Usage: "Do not verify the Vault server certificate",
+		},
+	}, authFlags()...)
+}
+
+// jobFlags are shared by backup and restore runs, they can be set by a job of the config file
+func jobFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  FlagConfig,
+			Usage: "HCL or YAML file describing jobs, flags on the command line take precedence",
+		},
+		&cli.StringFlag{
+			Name:  FlagJob,
+			Usage: "Job of the config file to run, required when it has several jobs",
+		},
+		&cli.StringSliceFlag{
+			Name:  FlagInclude,
+			Usage: "Only engines whose path matches a glob, eg: kv* or auth/*",
+		},
+		&cli.StringSliceFlag{
+			Name:  FlagExclude,
+			Usage: "Skip engines whose path matches a glob",
+		},
+		&cli.IntFlag{
+			Name:  FlagConcurrency,
+			Usage: "Number of engines processed at the same time",
+			Value: 1,
+		},
+		&cli.StringFlag{
+			Name:  FlagEncryptionKeyFile,
+			Usage: "File holding a 32 bytes AES-256 key, raw or base64 encoded, to encrypt or decrypt the backup archive",
+		},
+		&cli.StringFlag{
+			Name:  FlagEncryptionKeyEnv,
+			Usage: "Environment variable holding a base64 encoded AES-256 key",
+		},
+		&cli.StringFlag{
+			Name:  FlagReport,
+			Usage: "Write a JSON report of the run summarizing each engine",
+		},
+		&cli.StringFlag{
+			Name:  FlagMetricsFile,
+			Usage: "Write Prometheus metrics of the run for the node exporter textfile collector, eg: /var/lib/node_exporter/hs-vault.prom",
+		},
+		&cli.BoolFlag{
+			Name:  FlagContinueOnError,
+			Usage: "Skip failed keys and engines and go on with the others, the run exits with 2 when it partially succeeded",
+		},
+		&cli.BoolFlag{
+			Name:  FlagSkipPreflight,
+			Usage: "Do not check the capabilities of the token before the run",
+		},
+	}
+}
+
+// retentionFlags write each backup run into a timestamped directory of dest and prune old runs,
+// a run is kept when any of them keeps it
+func retentionFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:  FlagKeep,
+			Usage: "Write each run into a timestamped directory of dest and keep the last runs",
+		},
+		&cli.IntFlag{
+			Name:  FlagKeepDaily,
+			Usage: "Keep the last run of each of the last days",
+		},
+		&cli.IntFlag{
+			Name:  FlagKeepWeekly,
+			Usage: "Keep the last run of each of the last weeks",
+		},
+		&cli.IntFlag{
+			Name:  FlagKeepMonthly,
+			Usage: "Keep the last run of each of the last months",
+		},
+	}
+}
+
 func getCommand() []*cli.Command {
 	return []*cli.Command{
 		{
 			Name:   "backup",
 			Usage:  "Run backup",
 			Action: backup,
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.StringFlag{
 					Name:    FlagPath,
 					Aliases: []string{"p"},
@@ -28,9 +176,13 @@ func getCommand() []*cli.Command {
 				&cli.StringFlag{
 					Name:    FlagDest,
 					Aliases: []string{"d"},
-					Usage:   "Local directory to store backup",
+					Usage:   "Local directory or s3://bucket/prefix to store backup",
 					Value:   "backup",
 				},
+				&cli.BoolFlag{
+					Name:  FlagTimestamped,
+					Usage: "Write the run into a new timestamped directory of dest, always set for runs kept with retention",
+				},
 				&cli.BoolFlag{
 					Name:    FlagCompress,
 					Aliases: []string{"c"},
@@ -54,18 +206,26 @@ func getCommand() []*cli.Command {
 					Aliases: []string{"n"},
 					Usage:   "Vault namespace",
 				},
+				&cli.BoolFlag{
+					Name:  FlagRecursive,
+					Usage: "Include child namespaces recursively (Vault Enterprise)",
+				},
 				&cli.BoolFlag{
 					Name:    FlagUseRaw,
 					Aliases: []string{"r"},
 					Usage:   "Use sys/raw endpoint to backup",
 				},
-			},
+				&cli.BoolFlag{
+					Name:  FlagSecretIDs,
+					Usage: "Backup AppRole secret-id accessors metadata for reference",
+				},
+			}, append(connectionFlags(), append(jobFlags(), retentionFlags()...)...)...),
 		},
 		{
 			Name:   "restore",
 			Usage:  "Run restore",
 			Action: restore,
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.StringFlag{
 					Name:    FlagPath,
 					Aliases: []string{"p"},
@@ -74,7 +234,7 @@ func getCommand() []*cli.Command {
 				&cli.StringFlag{
 					Name:    FlagSource,
 					Aliases: []string{"s"},
-					Usage:   "Local directory to store backup",
+					Usage:   "Local directory or s3://bucket/prefix to restore from",
 					Value:   "backup",
 				},
 				&cli.StringFlag{
@@ -88,11 +248,331 @@ func getCommand() []*cli.Command {
 					Aliases: []string{"n"},
 					Usage:   "Vault namespace",
 				},
+				&cli.BoolFlag{
+					Name:  FlagRecursive,
+					Usage: "Include child namespaces recursively (Vault Enterprise)",
+				},
 				&cli.BoolFlag{
 					Name:    FlagUseRaw,
 					Aliases: []string{"r"},
 					Usage:   "Use sys/raw endpoint to backup",
 				},
+				&cli.StringSliceFlag{
+					Name:  FlagAuditRewrite,
+					Usage: "Rewrite audit device file_path or address prefix, eg: /var/log/vault=/vault/logs",
+				},
+				&cli.StringSliceFlag{
+					Name:  FlagRemapPath,
+					Usage: "Remap mount path referenced by quotas, eg: kv=kv-new",
+				},
+				&cli.BoolFlag{
+					Name:  FlagRenumber,
+					Usage: "Import exported transit keys whose oldest versions were trimmed, versions are renumbered from 1",
+				},
+				&cli.BoolFlag{
+					Name:  FlagVerify,
+					Usage: "Read back restored keys and fail on mismatches with the backup",
+				},
+			}, append(connectionFlags(), jobFlags()...)...),
+		},
+		{
+			Name:   "verify",
+			Usage:  "Verify backup files against their checksums",
+			Action: verify,
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:    FlagPath,
+					Aliases: []string{"p"},
+					Usage:   "Secret engine path to verify",
+				},
+				&cli.StringFlag{
+					Name:    FlagSource,
+					Aliases: []string{"s"},
+					Usage:   "Local directory to store backup",
+					Value:   "backup",
+				},
+				&cli.StringFlag{
+					Name:    FlagLogLevel,
+					Aliases: []string{"l"},
+					Usage:   "Log level (debug, info, warn, error, dpanic, panic, fatal)",
+					Value:   "info",
+				},
+				&cli.StringFlag{
+					Name:    FlagNamespace,
+					Aliases: []string{"n"},
+					Usage:   "Vault namespace",
+				},
+				&cli.BoolFlag{
+					Name:  FlagLive,
+					Usage: "Compare checksums with secrets read again from Vault to report drift",
+				},
+			}, connectionFlags()...),
+		},
+		{
+			Name:   "inspect",
+			Usage:  "Browse and decode a backup offline",
+			Action: inspect,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    FlagSource,
+					Aliases: []string{"s"},
+					Usage:   "Local directory to store backup",
+					Value:   "backup",
+				},
+				&cli.StringFlag{
+					Name:    FlagNamespace,
+					Aliases: []string{"n"},
+					Usage:   "Vault namespace, backed up with --recursive-namespaces",
+				},
+				&cli.StringFlag{
+					Name:    FlagPath,
+					Aliases: []string{"p"},
+					Usage:   "Secret engine path to list keys of",
+				},
+				&cli.StringFlag{
+					Name:    FlagKey,
+					Aliases: []string{"k"},
+					Usage:   "Key to decode, eg: a kv secret, roles/<name> or config",
+				},
+				&cli.IntFlag{
+					Name:  FlagVersion,
+					Usage: "Version of a kv v2 secret, the current version by default",
+				},
+				&cli.StringFlag{
+					Name:    FlagFormat,
+					Aliases: []string{"o"},
+					Usage:   "Output format (json, yaml, table)",
+					Value:   "table",
+				},
+				&cli.BoolFlag{
+					Name:  FlagShowSecrets,
+					Usage: "Print secret values instead of masking them",
+				},
+			},
+		},
+		{
+			Name:   "export",
+			Usage:  "Export a kv engine backup to JSON, YAML or dotenv files",
+			Action: export,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    FlagSource,
+					Aliases: []string{"s"},
+					Usage:   "Local directory to store backup",
+					Value:   "backup",
+				},
+				&cli.StringFlag{
+					Name:    FlagNamespace,
+					Aliases: []string{"n"},
+					Usage:   "Vault namespace, backed up with --recursive-namespaces",
+				},
+				&cli.StringFlag{
+					Name:     FlagPath,
+					Aliases:  []string{"p"},
+					Usage:    "KV engine path to export",
+					Required: true,
+				},
+				&cli.StringSliceFlag{
+					Name:  FlagFilter,
+					Usage: "Export keys matching a glob or below a path, eg: app/* or app",
+				},
+				&cli.StringFlag{
+					Name:    FlagFormat,
+					Aliases: []string{"o"},
+					Usage:   "Output format (json, yaml, dotenv), dotenv only exports secrets whose values are all strings",
+					Value:   "json",
+				},
+				&cli.StringFlag{
+					Name:  FlagOut,
+					Usage: "Output file, or directory for dotenv, - prints to stdout",
+					Value: "-",
+				},
+			},
+		},
+		{
+			Name:   "import",
+			Usage:  "Import JSON, YAML, dotenv, Kubernetes Secrets, SOPS or vault-export files into a kv engine",
+			Action: importSecrets,
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:     FlagPath,
+					Aliases:  []string{"p"},
+					Usage:    "KV engine path to import into",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     FlagFile,
+					Aliases:  []string{"f"},
+					Usage:    "File to import, or directory of dotenv files",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:    FlagFormat,
+					Aliases: []string{"o"},
+					Usage:   "Input format (json, yaml, dotenv, kubernetes, sops, vault-export)",
+					Value:   "json",
+				},
+				&cli.StringFlag{
+					Name:  FlagPathTemplate,
+					Usage: "Where each secret lands, fields: .Key .Name .Namespace .Type .Labels .File, eg: k8s/{{.Namespace}}/{{.Name}}",
+					Value: transfer.DefaultPathTemplate,
+				},
+				&cli.StringSliceFlag{
+					Name:  FlagFilter,
+					Usage: "Import keys matching a glob or below a path, eg: app/* or app",
+				},
+				&cli.StringFlag{
+					Name:    FlagNamespace,
+					Aliases: []string{"n"},
+					Usage:   "Vault namespace",
+				},
+				&cli.StringFlag{
+					Name:    FlagLogLevel,
+					Aliases: []string{"l"},
+					Usage:   "Log level (debug, info, warn, error, dpanic, panic, fatal)",
+					Value:   "info",
+				},
+			}, connectionFlags()...),
+		},
+		{
+			Name:   "preflight",
+			Usage:  "Check the token has the capabilities a backup or restore needs",
+			Action: preflight,
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:  FlagOperation,
+					Usage: "Operation to check (backup, restore)",
+					Value: "backup",
+				},
+				&cli.StringFlag{
+					Name:    FlagPath,
+					Aliases: []string{"p"},
+					Usage:   "Secret engine path to check",
+				},
+				&cli.StringFlag{
+					Name:    FlagNamespace,
+					Aliases: []string{"n"},
+					Usage:   "Vault namespace",
+				},
+				&cli.BoolFlag{
+					Name:  FlagRecursive,
+					Usage: "Include child namespaces recursively (Vault Enterprise)",
+				},
+				&cli.StringSliceFlag{
+					Name:  FlagInclude,
+					Usage: "Only engines whose path matches a glob, eg: kv* or auth/*",
+				},
+				&cli.StringSliceFlag{
+					Name:  FlagExclude,
+					Usage: "Skip engines whose path matches a glob",
+				},
+				&cli.BoolFlag{
+					Name:  FlagVerify,
+					Usage: "Restore reads back restored keys",
+				},
+				&cli.StringFlag{
+					Name:  FlagConfig,
+					Usage: "HCL or YAML file describing jobs, flags on the command line take precedence",
+				},
+				&cli.StringFlag{
+					Name:  FlagJob,
+					Usage: "Job of the config file to check",
+				},
+				&cli.StringFlag{
+					Name:    FlagFormat,
+					Aliases: []string{"o"},
+					Usage:   "Output format (json, yaml, table)",
+					Value:   "table",
+				},
+				&cli.StringFlag{
+					Name:    FlagLogLevel,
+					Aliases: []string{"l"},
+					Usage:   "Log level (debug, info, warn, error, dpanic, panic, fatal)",
+					Value:   "info",
+				},
+			}, connectionFlags()...),
+		},
+		{
+			Name:   "policy",
+			Usage:  "Generate the least privilege ACL policy of a backup or restore token",
+			Action: policy,
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:  FlagOperation,
+					Usage: "Operation to grant (backup, restore)",
+					Value: "backup",
+				},
+				&cli.BoolFlag{
+					Name:    FlagUseRaw,
+					Aliases: []string{"r"},
+					Usage:   "Grant sys/raw paths, engines back up more configuration with them",
+				},
+				&cli.StringFlag{
+					Name:    FlagPath,
+					Aliases: []string{"p"},
+					Usage:   "Secret engine path to grant",
+				},
+				&cli.StringFlag{
+					Name:    FlagNamespace,
+					Aliases: []string{"n"},
+					Usage:   "Vault namespace the policy is written in",
+				},
+				&cli.BoolFlag{
+					Name:  FlagRecursive,
+					Usage: "Include child namespaces recursively (Vault Enterprise)",
+				},
+				&cli.StringSliceFlag{
+					Name:  FlagInclude,
+					Usage: "Only engines whose path matches a glob, eg: kv* or auth/*",
+				},
+				&cli.StringSliceFlag{
+					Name:  FlagExclude,
+					Usage: "Skip engines whose path matches a glob",
+				},
+				&cli.BoolFlag{
+					Name:  FlagVerify,
+					Usage: "Restore reads back restored keys",
+				},
+				&cli.StringFlag{
+					Name:  FlagConfig,
+					Usage: "HCL or YAML file describing jobs, flags on the command line take precedence",
+				},
+				&cli.StringFlag{
+					Name:  FlagJob,
+					Usage: "Job of the config file to grant",
+				},
+				&cli.StringFlag{
+					Name:  FlagOut,
+					Usage: "Output file, - prints to stdout",
+					Value: "-",
+				},
+				&cli.StringFlag{
+					Name:    FlagLogLevel,
+					Aliases: []string{"l"},
+					Usage:   "Log level (debug, info, warn, error, dpanic, panic, fatal)",
+					Value:   "info",
+				},
+			}, connectionFlags()...),
+		},
+		{
+			Name:   "daemon",
+			Usage:  "Run backup jobs of a config file on their schedule",
+			Action: daemon,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     FlagConfig,
+					Usage:    "HCL or YAML file describing jobs, jobs with a schedule are run",
+					Required: true,
+				},
+				&cli.StringSliceFlag{
+					Name:  FlagJob,
+					Usage: "Only run these jobs",
+				},
+				&cli.StringFlag{
+					Name:  FlagListen,
+					Usage: "Address of the HTTP health endpoint /health",
+					Value: ":8080",
+				},
 			},
 		},
 	}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/robfig/cron/v3"
+	"github.com/urfave/cli/v2"
+	"github.com/zduymz/hs-vault/config"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// jobStatus is the state of a scheduled job reported by the health endpoint
+type jobStatus struct {
+	Name        string     `json:"name"`
+	Schedule    string     `json:"schedule"`
+	Running     bool       `json:"running"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	NextRun     *time.Time `json:"next_run,omitempty"`
+
+	entry cron.EntryID
+}
+
+// scheduler runs backup jobs of the config file on their cron schedule
+type scheduler struct {
+	c    *cli.Context
+	cron *cron.Cron
+
+	mu   sync.Mutex
+	jobs map[string]*jobStatus
+}
+
+func daemon(c *cli.Context) error {
+	cfg, err := config.Load(c.String(FlagConfig))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	s := &scheduler{
+		c:    c,
+		cron: cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.PrintfLogger(log.Default())))),
+		jobs: map[string]*jobStatus{},
+	}
+	if err := s.schedule(cfg, c.StringSlice(FlagJob)); err != nil {
+		log.Fatalln(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.health)
+	mux.Handle("/metrics", promhttp.HandlerFor(runMetrics.Registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Addr: c.String(FlagListen), Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalln(err)
+		}
+	}()
+
+	s.cron.Start()
+	log.Printf("Scheduled %d jobs, health on '%v/health', metrics on '%v/metrics'", len(s.jobs), c.String(FlagListen), c.String(FlagListen))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	// running jobs are finished before exiting
+	log.Println("Stopping, waiting for running jobs")
+	<-s.cron.Stop().Done()
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdown)
+}
+
+// schedule adds the jobs having a schedule, only the named ones when names are given
+func (s *scheduler) schedule(cfg *config.Config, names []string) error {
+	selected := map[string]bool{}
+	for _, name := range names {
+		if _, err := cfg.Job(name); err != nil {
+			return err
+		}
+		selected[name] = true
+	}
+
+	for _, job := range cfg.Jobs {
+		if len(selected) > 0 && !selected[job.Name] {
+			continue
+		}
+		if job.Schedule == "" {
+			log.Printf("Job '%v' has no schedule, skipped", job.Name)
+			continue
+		}
+
+		name := job.Name
+		id, err := s.cron.AddFunc(job.Schedule, func() { s.run(name) })
+		if err != nil {
+			return fmt.Errorf("job '%v': %w", name, err)
+		}
+		s.jobs[name] = &jobStatus{Name: name, Schedule: job.Schedule, entry: id}
+	}
+
+	if len(s.jobs) == 0 {
+		return fmt.Errorf("no job with a schedule in '%v'", s.c.String(FlagConfig))
+	}
+	return nil
+}
+
+// run runs a backup of the job and records its outcome
+func (s *scheduler) run(name string) {
+	start := time.Now()
+	s.update(name, func(j *jobStatus) {
+		j.Running = true
+		j.LastRun = &start
+	})
+	log.Printf("Job '%v' started", name)
+
+	err := s.backup(name)
+
+	s.update(name, func(j *jobStatus) {
+		j.Running = false
+		j.LastError = ""
+		if err != nil {
+			j.LastError = err.Error()
+			return
+		}
+		end := time.Now()
+		j.LastSuccess = &end
+	})
+
+	if err != nil {
+		log.Printf("Job '%v' failed after %v: %v", name, time.Since(start).Round(time.Second), err)
+		return
+	}
+	log.Printf("Job '%v' finished in %v", name, time.Since(start).Round(time.Second))
+}
+
+// backup runs the backup command as if it was called with --config, --job and --timestamped, runs never
+// overwrite each other so files removed from Vault are not restored from an older run
+func (s *scheduler) backup(name string) error {
+	cmd := s.c.App.Command("backup")
+	set := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	for _, f := range cmd.Flags {
+		if err := f.Apply(set); err != nil {
+			return err
+		}
+	}
+
+	c := cli.NewContext(s.c.App, set, s.c)
+	c.Command = cmd
+	if err := c.Set(FlagConfig, s.c.String(FlagConfig)); err != nil {
+		return err
+	}
+	if err := c.Set(FlagJob, name); err != nil {
+		return err
+	}
+	if err := c.Set(FlagTimestamped, "true"); err != nil {
+		return err
+	}
+	return runBackup(c)
+}
+
+func (s *scheduler) update(name string, fn func(j *jobStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.jobs[name])
+}
+
+// health reports every job, it fails with 503 when the last run of a job failed
+func (s *scheduler) health(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	status := http.StatusOK
+	jobs := make([]jobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		job := *j
+		if next := s.cron.Entry(j.entry).Next; !next.IsZero() {
+			job.NextRun = &next
+		}
+		if job.LastError != "" {
+			status = http.StatusServiceUnavailable
+		}
+		jobs = append(jobs, job)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Name < jobs[k].Name })
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"healthy": status == http.StatusOK,
+		"jobs":    jobs,
+	})
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"github.com/zduymz/hs-vault"
+	"github.com/zduymz/hs-vault/backends"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const maskedValue = "********"
+
+// sensitiveFields are masked in roles, configuration and raw entries, every value of kv secrets is masked
+var sensitiveFields = []string{"password", "secret", "token", "private", "jwt", "credentials", "bindpass", "backup"}
+
+func inspect(c *cli.Context) error {
+	source := hsvault.NamespaceDir(c.String(FlagSource), c.String(FlagNamespace))
+	backups, err := hsvault.ListBackupEngines(source)
+	if err != nil {
+		return err
+	}
+
+	// list engines
+	if !c.IsSet(FlagPath) {
+		var keys []string
+		for key := range backups {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var rows [][]string
+		for _, key := range keys {
+			rows = append(rows, []string{key, backups[key]})
+		}
+		return render(c, []string{"PATH", "TYPE"}, rows)
+	}
+
+	key := c.String(FlagPath)
+	engineType, ok := backups[key]
+	if !ok {
+		return fmt.Errorf("engine with path '%v' not found in '%v'", key, source)
+	}
+	dir := path.Join(source, fmt.Sprintf("%v.%v", key, engineType))
+
+	// list keys
+	if !c.IsSet(FlagKey) {
+		keys, err := listBackupKeys(dir, backends.EngineType(engineType))
+		if err != nil {
+			return err
+		}
+
+		var rows [][]string
+		for _, k := range keys {
+			rows = append(rows, []string{k})
+		}
+		return render(c, []string{"KEY"}, rows)
+	}
+
+	value, err := decodeBackupKey(c, dir, backends.EngineType(engineType), c.String(FlagKey))
+	if err != nil {
+		return err
+	}
+	return render(c, nil, value)
+}
+
+// listBackupKeys returns keys of kv engines from their chunks, files of other engines
+func listBackupKeys(dir string, engineType backends.EngineType) ([]string, error) {
+	var keys []string
+
+	if engineType == backends.SecretV1Engine || engineType == backends.SecretV2Engine {
+		chunks, err := backends.ReadChunks(dir)
+		if err != nil {
+			return nil, err
+		}
+		for k := range chunks {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys, nil
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, filepath.ToSlash(rel))
+		return nil
+	})
+	return keys, err
+}
+
+// decodeBackupKey returns a secret, a role, a configuration or a raw entry of the engine backup
+func decodeBackupKey(c *cli.Context, dir string, engineType backends.EngineType, key string) (interface{}, error) {
+	switch engineType {
+	case backends.SecretV1Engine, backends.SecretV2Engine:
+		chunks, err := backends.ReadChunks(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		content, ok := chunks[key]
+		if !ok {
+			return nil, fmt.Errorf("key '%v' not found", key)
+		}
+
+		if engineType == backends.SecretV1Engine {
+			var data map[string]interface{}
+			if err := json.Unmarshal(content, &data); err != nil {
+				return nil, err
+			}
+			return mask(c, data, true), nil
+		}
+
+		var backup backends.SecretV2Backup
+		if err := json.Unmarshal(content, &backup); err != nil {
+			return nil, err
+		}
+
+		version := backup.MetaData.CurrentVersion
+		if c.IsSet(FlagVersion) {
+			version = c.Int(FlagVersion)
+		}
+
+		meta, ok := backup.MetaData.Versions[version]
+		if !ok {
+			return nil, fmt.Errorf("version %d of key '%v' not found", version, key)
+		}
+
+		var data interface{}
+		if d := backup.Data[version]; d != nil {
+			data = mask(c, d, true)
+		}
+		return map[string]interface{}{
+			"version":         version,
+			"current_version": backup.MetaData.CurrentVersion,
+			"destroyed":       meta.Destroyed,
+			"deletion_time":   meta.DeletionTime,
+			"custom_metadata": backup.MetaData.CustomerMetadata,
+			"data":            data,
+		}, nil
+	}
+
+	content, err := os.ReadFile(path.Join(dir, key))
+	if err != nil {
+		return nil, err
+	}
+	return mask(c, decodeFile(content), false), nil
+}
+
+// decodeFile returns base64 encoded JSON and JSON as values, anything else, eg: policies, as text
+func decodeFile(content []byte) interface{} {
+	var value interface{}
+	if bs, err := base64.StdEncoding.DecodeString(string(content)); err == nil {
+		if json.Unmarshal(bs, &value) == nil {
+			return value
+		}
+	}
+	if json.Unmarshal(content, &value) == nil {
+		return value
+	}
+	return string(content)
+}
+
+// mask replaces values of sensitive fields, or every value, unless --show-secrets is set
+func mask(c *cli.Context, value interface{}, all bool) interface{} {
+	if c.Bool(FlagShowSecrets) {
+		return value
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		output := map[string]interface{}{}
+		for k, x := range v {
+			output[k] = mask(c, x, all || sensitive(k))
+		}
+		return output
+	case []interface{}:
+		output := make([]interface{}, len(v))
+		for i, x := range v {
+			output[i] = mask(c, x, all)
+		}
+		return output
+	case nil:
+		return nil
+	}
+
+	if all {
+		return maskedValue
+	}
+	return value
+}
+
+func sensitive(field string) bool {
+	field = strings.ToLower(field)
+	if field == "key" || field == "keys" {
+		return true
+	}
+	for _, s := range sensitiveFields {
+		if strings.Contains(field, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// render prints rows under header, or a single value, in the requested format
+func render(c *cli.Context, header []string, value interface{}) error {
+	format := c.String(FlagFormat)
+
+	// listings are printed as objects keyed by the lower-cased header
+	if rows, ok := value.([][]string); ok && format != "table" {
+		var items []map[string]string
+		for _, row := range rows {
+			item := map[string]string{}
+			for i, h := range header {
+				item[strings.ToLower(h)] = row[i]
+			}
+			items = append(items, item)
+		}
+		value = items
+	}
+
+	switch format {
+	case "json":
+		bs, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(bs))
+	case "yaml":
+		bs, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(bs))
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		switch v := value.(type) {
+		case [][]string:
+			fmt.Fprintln(w, strings.Join(header, "\t"))
+			for _, row := range v {
+				fmt.Fprintln(w, strings.Join(row, "\t"))
+			}
+		case map[string]interface{}:
+			var keys []string
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			fmt.Fprintln(w, "KEY\tVALUE")
+			for _, k := range keys {
+				fmt.Fprintf(w, "%v\t%v\n", k, tableValue(v[k]))
+			}
+		default:
+			fmt.Fprintln(w, tableValue(v))
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown format '%v', expected json, yaml or table", format)
+	}
+	return nil
+}
+
+// tableValue prints scalars as is and nested values as compact JSON
+func tableValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}, []interface{}, map[string]string:
+		bs, _ := json.Marshal(v)
+		return string(bs)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"github.com/zduymz/hs-vault/config"
+	"strconv"
+)
+
+// applyJob sets flags from the selected job of the config file,
+// flags given on the command line take precedence over the job
+func applyJob(c *cli.Context) error {
+	if !c.IsSet(FlagConfig) {
+		return nil
+	}
+
+	cfg, err := config.Load(c.String(FlagConfig))
+	if err != nil {
+		return err
+	}
+
+	job, err := cfg.Job(c.String(FlagJob))
+	if err != nil {
+		return err
+	}
+
+	values := map[string][]string{
+		FlagAddress:           {job.Vault.Address},
+		FlagCACert:            {job.Vault.CACert},
+		FlagSkipVerify:        {boolValue(job.Vault.SkipVerify)},
+		FlagAuthMethod:        {job.Vault.Auth.Method},
+		FlagAuthMount:         {job.Vault.Auth.Mount},
+		FlagTokenFile:         {job.Vault.Auth.TokenFile},
+		FlagRoleID:            {job.Vault.Auth.RoleID},
+		FlagRoleIDFile:        {job.Vault.Auth.RoleIDFile},
+		FlagSecretIDFile:      {job.Vault.Auth.SecretIDFile},
+		FlagAuthRole:          {job.Vault.Auth.Role},
+		FlagJWTFile:           {job.Vault.Auth.JWTFile},
+		FlagUsername:          {job.Vault.Auth.Username},
+		FlagPasswordFile:      {job.Vault.Auth.PasswordFile},
+		FlagNamespace:         {job.Namespace},
+		FlagRecursive:         {boolValue(job.RecursiveNamespaces)},
+		FlagInclude:           job.Include,
+		FlagExclude:           job.Exclude,
+		FlagDest:              {job.Destination},
+		FlagSource:            {job.Destination},
+		FlagEncryptionKeyFile: {job.Encryption.KeyFile},
+		FlagEncryptionKeyEnv:  {job.Encryption.KeyEnv},
+		FlagCompress:          {boolValue(job.Compression == "tar.gz")},
+		FlagKeep:              {intValue(job.Retention.Keep)},
+		FlagKeepDaily:         {intValue(job.Retention.Daily)},
+		FlagKeepWeekly:        {intValue(job.Retention.Weekly)},
+		FlagKeepMonthly:       {intValue(job.Retention.Monthly)},
+		FlagConcurrency:       {intValue(job.Concurrency)},
+		FlagUseRaw:            {boolValue(job.Raw)},
+		FlagSecretIDs:         {boolValue(job.ApproleSecretIDs)},
+		FlagAuditRewrite:      job.AuditRewrite,
+		FlagRemapPath:         job.RemapPath,
+		FlagRenumber:          {boolValue(job.TransitRenumberVersions)},
+		FlagVerify:            {boolValue(job.Verify)},
+		FlagLogLevel:          {job.LogLevel},
+		FlagReport:            {job.Report},
+		FlagMetricsFile:       {job.MetricsFile},
+		FlagContinueOnError:   {boolValue(job.ContinueOnError)},
+		FlagSkipPreflight:     {boolValue(job.SkipPreflight)},
+	}
+
+	for name, vs := range values {
+		if c.IsSet(name) || !hasFlag(c, name) {
+			continue
+		}
+		for _, v := range vs {
+			if v == "" {
+				continue
+			}
+			if err := c.Set(name, v); err != nil {
+				return fmt.Errorf("job '%v': %v: %w", job.Name, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// hasFlag reports whether the running command defines the flag
+func hasFlag(c *cli.Context, name string) bool {
+	for _, f := range c.Command.Flags {
+		for _, n := range f.Names() {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// boolValue returns "true" or nothing, false leaves the flag default
+func boolValue(v bool) string {
+	if v {
+		return "true"
+	}
+	return ""
+}
+
+// intValue returns the number or nothing, 0 leaves the flag default
+func intValue(v int) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.Itoa(v)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/vault-client-go"
+	"github.com/urfave/cli/v2"
+	"github.com/zduymz/hs-vault/backends"
+	"github.com/zduymz/hs-vault/login"
+	"log"
+	"strings"
+)
+
+// authFlags select how the tool logs in to Vault, VAULT_TOKEN is used by default
+func authFlags() []cli.Flag {
+	methods := make([]string, len(login.Supported))
+	for i, m := range login.Supported {
+		methods[i] = string(m)
+	}
+
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  FlagAuthMethod,
+			Usage: "Auth method of the tool, one of " + strings.Join(methods, ", "),
+			Value: string(login.Token),
+		},
+		&cli.StringFlag{
+			Name:  FlagAuthMount,
+			Usage: "Path of the auth method, defaults to the method name",
+		},
+		&cli.StringFlag{
+			Name:  FlagTokenFile,
+			Usage: "token-file: file holding the token, eg: a Vault agent sink, it is read again every minute",
+		},
+		&cli.StringFlag{
+			Name:    FlagRoleID,
+			Usage:   "approle: role id",
+			EnvVars: []string{"VAULT_ROLE_ID"},
+		},
+		&cli.StringFlag{
+			Name:  FlagRoleIDFile,
+			Usage: "approle: file holding the role id",
+		},
+		&cli.StringFlag{
+			Name:    FlagSecretID,
+			Usage:   "approle: secret id",
+			EnvVars: []string{"VAULT_SECRET_ID"},
+		},
+		&cli.StringFlag{
+			Name:  FlagSecretIDFile,
+			Usage: "approle: file holding the secret id",
+		},
+		&cli.StringFlag{
+			Name:  FlagAuthRole,
+			Usage: "kubernetes: role to log in with",
+		},
+		&cli.StringFlag{
+			Name:  FlagJWTFile,
+			Usage: "kubernetes: service account token file",
+			Value: login.DefaultJWTFile,
+		},
+		&cli.StringFlag{
+			Name:  FlagUsername,
+			Usage: "userpass: username",
+		},
+		&cli.StringFlag{
+			Name:    FlagPassword,
+			Usage:   "userpass: password",
+			EnvVars: []string{"VAULT_PASSWORD"},
+		},
+		&cli.StringFlag{
+			Name:  FlagPasswordFile,
+			Usage: "userpass: file holding the password",
+		},
+	}
+}
+
+func loginConfig(c *cli.Context) (login.Config, error) {
+	method := login.Method(c.String(FlagAuthMethod))
+	config := login.Config{
+		Method:       method,
+		Mount:        c.String(FlagAuthMount),
+		RoleID:       c.String(FlagRoleID),
+		RoleIDFile:   c.String(FlagRoleIDFile),
+		SecretID:     c.String(FlagSecretID),
+		SecretIDFile: c.String(FlagSecretIDFile),
+		Role:         c.String(FlagAuthRole),
+		JWTFile:      c.String(FlagJWTFile),
+		Username:     c.String(FlagUsername),
+		Password:     c.String(FlagPassword),
+		PasswordFile: c.String(FlagPasswordFile),
+		TokenFile:    c.String(FlagTokenFile),
+	}
+
+	switch method {
+	case login.Token:
+	case login.TokenFile:
+		if config.TokenFile == "" {
+			return config, fmt.Errorf("--%v is required by the token-file auth method", FlagTokenFile)
+		}
+	case login.AppRole:
+		if config.RoleID == "" && config.RoleIDFile == "" {
+			return config, fmt.Errorf("--%v or --%v is required by the approle auth method", FlagRoleID, FlagRoleIDFile)
+		}
+	case login.Kubernetes:
+		if config.Role == "" {
+			return config, fmt.Errorf("--%v is required by the kubernetes auth method", FlagAuthRole)
+		}
+	case login.Userpass:
+		if config.Username == "" {
+			return config, fmt.Errorf("--%v is required by the userpass auth method", FlagUsername)
+		}
+	default:
+		return config, fmt.Errorf("unsupported auth method '%v'", method)
+	}
+	return config, nil
+}
+
+// vaultLogin logs the client in and keeps its token valid,
+// the returned function revokes tokens the tool logged in with
+func vaultLogin(c *cli.Context, client *vault.Client) (func(), error) {
+	config, err := loginConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := login.Login(context.Background(), client, config, backends.NewLogger(c.String(FlagLogLevel)))
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if err := session.Close(context.Background()); err != nil {
+			log.Println(err)
+		}
+	}, nil
+}
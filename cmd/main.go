@@ -1,230 +1,163 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"github.com/hashicorp/vault-client-go"
-	"github.com/mitchellh/mapstructure"
 	"github.com/urfave/cli/v2"
+	"github.com/zduymz/hs-vault"
+	"github.com/zduymz/hs-vault/archive"
 	"github.com/zduymz/hs-vault/backends"
+	"github.com/zduymz/hs-vault/metrics"
 	"log"
 	"os"
-	"path"
 	"strings"
 )
 
-type SecretEngineResponse struct {
-	Accessor    string
-	Description string
-	Local       bool
-	Type        string
-	Uuid        string
-	Options     map[string]interface{}
-}
-
-func (engine *SecretEngineResponse) getEngineType() backends.EngineType {
-	if engine.Type == "kv" {
-		if version, ok := engine.Options["version"]; ok && version.(string) == "2" {
-			return backends.SecretV2Engine
+// parseMapping parses a list of old=new values
+func parseMapping(values []string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, value := range values {
+		from, to, ok := strings.Cut(value, "=")
+		if !ok || from == "" {
+			return nil, fmt.Errorf("invalid mapping '%v', expected old=new", value)
 		}
-		//if engine.Options["version"].(string) == "2" {
-		//	return backends.SecretV2Engine
-		//}
-		return backends.SecretV1Engine
+		mapping[from] = to
 	}
-	return backends.EngineType(engine.Type)
+	return mapping, nil
 }
 
-func listEngines(v *vault.Client) (map[string]SecretEngineResponse, error) {
-	var secretEngines = make(map[string]SecretEngineResponse)
-	var ctx = context.Background()
-	engines, err := v.System.MountsListSecretsEngines(ctx)
+// getVaultClient returns a client configured by newVaultClient, it exits on errors
+func getVaultClient(c *cli.Context) (*vault.Client, func()) {
+	client, logout, err := newVaultClient(c)
 	if err != nil {
-		return nil, err
-	}
-
-	for key, value := range engines.Data {
-		key = strings.TrimSuffix(key, "/")
-		output := SecretEngineResponse{}
-		err := mapstructure.Decode(value, &output)
-		if err != nil {
-			log.Fatalln(err)
-		}
-
-		if output.Type == "system" || output.Type == "cubbyhole" || output.Type == "identity" {
-			continue
-		}
-
-		if output.Type == "consul" {
-			continue
-		}
-
-		if output.Type == "generic" {
-			continue
-		}
-		secretEngines[key] = output
+		log.Fatalln(err)
 	}
-	return secretEngines, nil
+	return client, logout
 }
 
-func checkRawAccessible(v *vault.Client) bool {
-	var ctx = context.Background()
-	_, err := v.System.RawList(ctx, "/")
-	if err != nil {
-		return false
+// newVaultClient returns a client configured from the environment, --address, --ca-cert
+// and --tls-skip-verify take precedence, it is logged in with --auth-method.
+// The returned function must be called once the client is not used anymore
+func newVaultClient(c *cli.Context) (*vault.Client, func(), error) {
+	options := []vault.ClientOption{vault.WithEnvironment(), countAPIErrors(c)}
+	if address := c.String(FlagAddress); address != "" {
+		options = append(options, vault.WithAddress(address))
+	}
+	if c.String(FlagCACert) != "" || c.Bool(FlagSkipVerify) {
+		options = append(options, vault.WithTLS(vault.TLSConfiguration{
+			ServerCertificate:  vault.ServerCertificateEntry{FromFile: c.String(FlagCACert)},
+			InsecureSkipVerify: c.Bool(FlagSkipVerify),
+		}))
 	}
-	return true
-}
 
-func getVaultClient() *vault.Client {
-	client, err := vault.New(vault.WithEnvironment())
+	client, err := vault.New(options...)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, nil, err
 	}
-	if err := client.SetToken(os.Getenv("VAULT_TOKEN")); err != nil {
-		log.Fatalln(err)
+	logout, err := vaultLogin(c, client)
+	if err != nil {
+		return nil, nil, err
 	}
-	return client
+	return client, logout, nil
 }
 
-func backup(c *cli.Context) error {
-	client := getVaultClient()
-	engines, err := listEngines(client)
+// runConfig returns the library config shared by backup and restore runs
+func runConfig(c *cli.Context) (hsvault.Config, error) {
+	key, err := archive.LoadKey(c.String(FlagEncryptionKeyFile), c.String(FlagEncryptionKeyEnv))
 	if err != nil {
-		log.Fatalln(err)
+		return hsvault.Config{}, err
 	}
 
-	rawAccessible := checkRawAccessible(client)
+	return hsvault.Config{
+		Job:             c.String(FlagJob),
+		Namespace:       c.String(FlagNamespace),
+		Recursive:       c.Bool(FlagRecursive),
+		Path:            c.String(FlagPath),
+		Include:         c.StringSlice(FlagInclude),
+		Exclude:         c.StringSlice(FlagExclude),
+		Concurrency:     c.Int(FlagConcurrency),
+		ContinueOnError: c.Bool(FlagContinueOnError),
+		Preflight:       !c.Bool(FlagSkipPreflight),
+		EncryptionKey:   key,
+		Base64Encode:    c.Bool(FlagB64Encode),
+		Logger:          backends.NewLogger(c.String(FlagLogLevel)),
+		Metrics:         runMetrics,
+	}, nil
+}
 
-	// namespace is set
-	if c.IsSet(FlagNamespace) {
-		if err := client.SetNamespace(c.String(FlagNamespace)); err != nil {
-			log.Fatalln(err)
-		}
+func backup(c *cli.Context) error {
+	return runBackup(c)
+}
+
+// runBackup runs a backup, the daemon runs its jobs with it
+func runBackup(c *cli.Context) error {
+	if err := applyJob(c); err != nil {
+		return err
 	}
 
-	// backup specific path
-	if c.IsSet(FlagPath) {
-		key := c.String(FlagPath)
-		engine, ok := engines[key]
-		if !ok {
-			log.Fatalf("Engine with path '%v' not found", key)
-		}
+	run, err := backupRun(c)
+	return finishRun(c, "backup", run, err)
+}
 
-		se := backends.NewSecretEngine(client,
-			&backends.SecretEngine{
-				Path: key,
-				Type: engine.Type,
-				UUID: engine.Uuid,
-			},
-			&backends.Options{
-				Base64Encode:  c.Bool(FlagB64Encode),
-				BackupPath:    c.String(FlagDest),
-				LogLevel:      c.String(FlagLogLevel),
-				RawAccessible: rawAccessible,
-			},
-			engine.getEngineType(),
-		)
-
-		err := se.Backup(context.Background())
-		if err != nil {
-			log.Fatalln(err)
-		}
-		return nil
+func backupRun(c *cli.Context) (*metrics.Run, error) {
+	config, err := runConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	config.BackupSecretIDs = c.Bool(FlagSecretIDs)
+	config.Compress = c.Bool(FlagCompress)
+	config.Retention = retention(c)
+	if config.Dir, config.Storage, err = backupStorage(c); err != nil {
+		return nil, err
 	}
 
-	//default backup all engines
-	for key, engine := range engines {
-		ss := backends.NewSecretEngine(client,
-			&backends.SecretEngine{
-				Path: key,
-				Type: engine.Type,
-				UUID: engine.Uuid,
-			},
-			&backends.Options{
-				Base64Encode:  c.Bool(FlagB64Encode),
-				BackupPath:    c.String(FlagDest),
-				LogLevel:      c.String(FlagLogLevel),
-				RawAccessible: rawAccessible,
-			},
-			engine.getEngineType(),
-		)
-		err := ss.Backup(context.Background())
-		if err != nil {
-			log.Fatalln(err)
-		}
+	client, logout, err := newVaultClient(c)
+	if err != nil {
+		return nil, err
 	}
+	defer logout()
 
-	return nil
+	return hsvault.Backup(c.Context, client, config)
 }
 
 func restore(c *cli.Context) error {
-	client := getVaultClient()
-	engines, err := listEngines(client)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	rawAccessible := checkRawAccessible(client)
+	return runRestore(c)
+}
 
-	// namespace is set
-	if c.IsSet(FlagNamespace) {
-		if err := client.SetNamespace(c.String(FlagNamespace)); err != nil {
-			log.Fatalln(err)
-		}
+// runRestore runs a restore, verification mismatches fail it
+func runRestore(c *cli.Context) error {
+	if err := applyJob(c); err != nil {
+		return err
 	}
 
-	// backup specific path
-	if c.IsSet(FlagPath) {
-		key := c.String(FlagPath)
-		engine, ok := engines[key]
-		if !ok {
-			log.Fatalf("Engine with path '%v' not found", key)
-		}
+	run, err := restoreRun(c)
+	return finishRun(c, "restore", run, err)
+}
 
-		se := backends.NewSecretEngine(client,
-			&backends.SecretEngine{
-				Path: key,
-				Type: engine.Type,
-				UUID: engine.Uuid,
-			},
-			&backends.Options{
-				Base64Encode:  c.Bool(FlagB64Encode),
-				LogLevel:      c.String(FlagLogLevel),
-				RestorePath:   c.String(FlagSource),
-				RawAccessible: rawAccessible,
-			},
-			engine.getEngineType(),
-		)
-
-		err := se.Restore(context.Background())
-		if err != nil {
-			log.Fatalln(err)
-		}
-		return nil
+func restoreRun(c *cli.Context) (*metrics.Run, error) {
+	config, err := runConfig(c)
+	if err != nil {
+		return nil, err
 	}
-	//default restore all engines
-	for key, engine := range engines {
-		ss := backends.NewSecretEngine(client,
-			&backends.SecretEngine{
-				Path: key,
-				Type: engine.Type,
-				UUID: engine.Uuid,
-			},
-			&backends.Options{
-				Base64Encode: c.Bool(FlagB64Encode),
-				RestorePath:  path.Join(c.String(FlagSource), fmt.Sprintf("%v.%v", key, engine.Type)),
-				LogLevel:     c.String(FlagLogLevel),
-			},
-			engine.getEngineType(),
-		)
-		err := ss.Restore(context.Background())
-		if err != nil {
-			log.Fatalln(err)
-		}
+	config.Verify = c.Bool(FlagVerify)
+	config.TransitRenumberVersions = c.Bool(FlagRenumber)
+	if config.AuditRewrites, err = parseMapping(c.StringSlice(FlagAuditRewrite)); err != nil {
+		return nil, err
+	}
+	if config.PathRemap, err = parseMapping(c.StringSlice(FlagRemapPath)); err != nil {
+		return nil, err
+	}
+	if config.Dir, config.Storage, err = restoreStorage(c); err != nil {
+		return nil, err
+	}
+
+	client, logout, err := newVaultClient(c)
+	if err != nil {
+		return nil, err
 	}
+	defer logout()
 
-	return nil
+	return hsvault.Restore(c.Context, client, config)
 }
 
 func main() {
@@ -261,4 +194,65 @@ Restore all engines:
 
 Restore single engine:
 	$ hs-vault restore -p <engine_path> -s <backup_dir>/<engine_path>.<engine_type>
+
+Auth methods are handled like engines with their path prefixed by auth/:
+	$ hs-vault backup -p auth/approle
+	$ hs-vault restore -p auth/approle -s <backup_dir>/auth/approle.approle
+
+ACL, password, EGP and RGP policies are backed up as readable files and restored first:
+	$ hs-vault restore -p sys/policies -s <backup_dir>/sys/policies.policy
+
+Audit devices are enabled again on restore, file paths or socket addresses can be rewritten, the longest prefix wins:
+	$ hs-vault restore -s <backup_dir> --audit-rewrite /var/log/vault=/vault/logs
+
+Backup and restore a namespace and all its children (Vault Enterprise), children are stored in <backup_dir>/namespaces/<name>:
+	$ hs-vault backup -n <vault_namespace> --recursive-namespaces
+	$ hs-vault restore -n <vault_namespace> --recursive-namespaces -s <backup_dir>
+
+Rate-limit and lease-count quotas are restored after engines, their mount paths can be remapped:
+	$ hs-vault restore -s <backup_dir> --remap-path kv=kv-new
+
+Verify backup files against checksums taken at backup time, and optionally against the live Vault:
+	$ hs-vault verify -s <backup_dir>
+	$ hs-vault verify -s <backup_dir> --live
+
+Read back restored keys, roles and configuration and fail on mismatches with the backup:
+	$ hs-vault restore -s <backup_dir> --verify
+
+Browse a backup offline, values are masked unless --show-secrets is set:
+	$ hs-vault inspect -s <backup_dir>
+	$ hs-vault inspect -s <backup_dir> -p <engine_path>
+	$ hs-vault inspect -s <backup_dir> -p <engine_path> -k <key> --version 2 -o yaml
+
+Export secrets of a kv engine backup and import them into a kv engine:
+	$ hs-vault export -s <backup_dir> -p <engine_path> --filter 'app/*' -o yaml --out secrets.yaml
+	$ hs-vault export -s <backup_dir> -p <engine_path> -o dotenv --out <dir>
+	$ hs-vault import -p <engine_path> -f secrets.yaml -o yaml
+
+Import Kubernetes Secret manifests, SOPS-decrypted YAML or vault-export JSON, the path template places each secret:
+	$ hs-vault import -p <engine_path> -f manifests/ -o kubernetes --path-template 'k8s/{{.Namespace}}/{{.Name}}'
+	$ hs-vault import -p <engine_path> -f secrets.dec.yaml -o sops --path-template '{{.File}}/{{.Key}}'
+	$ hs-vault import -p <engine_path> -f export.json -o vault-export --path-template '{{trimPrefix "secret/" .Key}}'
+
+Run a job of an HCL or YAML config file, flags on the command line take precedence:
+	$ hs-vault backup --config jobs.hcl --job nightly
+	$ hs-vault restore --config jobs.hcl --job nightly
+
+Keep runs grandfather-father-son style, locally or in S3 compatible storage:
+	$ hs-vault backup -d 's3://<bucket>/<prefix>?region=<region>' --keep 3 --keep-daily 7 --keep-weekly 4 --keep-monthly 12
+
+Run jobs of a config file on their schedule, with a health endpoint on /health and metrics on /metrics:
+	$ hs-vault daemon --config jobs.hcl --listen :8080
+
+Write a JSON run report and Prometheus metrics for the node exporter textfile collector:
+	$ hs-vault backup --report report.json --metrics-file /var/lib/node_exporter/hs-vault.prom
+
+Skip failed keys and engines instead of stopping, the run exits with 2 when it partially succeeded:
+	$ hs-vault backup --continue-on-error --report report.json
+
+Log in with AppRole, a Kubernetes service account, userpass or a Vault agent sink instead of VAULT_TOKEN,
+tokens are renewed during the run and revoked at the end:
+	$ VAULT_SECRET_ID=<secret_id> hs-vault backup --auth-method approle --role-id <role_id>
+	$ hs-vault backup --auth-method kubernetes --auth-role hs-vault
+	$ hs-vault backup --auth-method token-file --token-file /vault/agent/token
 `
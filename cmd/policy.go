@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"github.com/zduymz/hs-vault"
+	"log"
+	"os"
+)
+
+// policy prints the ACL policy granting what a backup or restore of the selected engines uses,
+// sys/raw paths are granted with --raw or when an engine requires them
+func policy(c *cli.Context) error {
+	if err := applyJob(c); err != nil {
+		return err
+	}
+
+	operation, err := runOperation(c)
+	if err != nil {
+		return err
+	}
+
+	config, err := runConfig(c)
+	if err != nil {
+		return err
+	}
+	config.Verify = c.Bool(FlagVerify)
+
+	client, logout, err := newVaultClient(c)
+	if err != nil {
+		return err
+	}
+	defer logout()
+
+	reqs, err := hsvault.Requirements(c.Context, client, config, operation, c.Bool(FlagUseRaw))
+	if err != nil {
+		return err
+	}
+
+	mode := "without sys/raw"
+	if c.Bool(FlagUseRaw) {
+		mode = "with sys/raw"
+	}
+	content := fmt.Sprintf("# hs-vault %v %v\n\n%v", operation, mode, hsvault.ACLPolicy(reqs, config.Namespace))
+
+	out := c.String(FlagOut)
+	if out == "-" {
+		fmt.Print(content)
+		return nil
+	}
+	if err := os.WriteFile(out, []byte(content), 0644); err != nil {
+		return err
+	}
+	log.Printf("Policy written to '%v', write it with: vault policy write <name> %v", out, out)
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"github.com/zduymz/hs-vault"
+	"log"
+	"strings"
+)
+
+// preflight prints the capabilities a backup or restore needs on every path and which ones the token lacks,
+// it fails when the run would be refused
+func preflight(c *cli.Context) error {
+	if err := applyJob(c); err != nil {
+		return err
+	}
+
+	operation, err := runOperation(c)
+	if err != nil {
+		return err
+	}
+
+	config, err := runConfig(c)
+	if err != nil {
+		return err
+	}
+	config.Verify = c.Bool(FlagVerify)
+
+	client, logout, err := newVaultClient(c)
+	if err != nil {
+		return err
+	}
+	defer logout()
+
+	report, err := hsvault.Preflight(c.Context, client, config, operation)
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	for _, check := range report.Checks {
+		status := "ok"
+		if len(check.Missing) > 0 {
+			status = "missing"
+			if check.Optional {
+				status = "degraded"
+			}
+		}
+		rows = append(rows, []string{check.Namespace, check.Engine, check.Type, check.Path,
+			strings.Join(check.Capabilities, ","), strings.Join(check.Missing, ","), status})
+	}
+	if err := render(c, []string{"NAMESPACE", "ENGINE", "TYPE", "PATH", "CAPABILITIES", "MISSING", "STATUS"}, rows); err != nil {
+		return err
+	}
+
+	degraded := map[string]bool{}
+	for _, check := range report.Degraded() {
+		if check.Engine != "" && !degraded[check.Engine] {
+			degraded[check.Engine] = true
+			log.Printf("Engine '%v' will be degraded, configuration only readable from sys/raw is skipped", strings.TrimPrefix(check.Namespace+"/"+check.Engine, "/"))
+		}
+	}
+	if !report.Raw {
+		log.Printf("sys/raw is not accessible")
+	}
+
+	if err := report.Err(); err != nil {
+		return cli.Exit(err, 1)
+	}
+	log.Printf("%v can start", strings.ToUpper(operation[:1])+operation[1:])
+	return nil
+}
+
+// runOperation returns the operation checked by preflight or granted by policy
+func runOperation(c *cli.Context) (string, error) {
+	operation := c.String(FlagOperation)
+	if operation != "backup" && operation != "restore" {
+		return "", fmt.Errorf("unknown operation '%v', expected backup or restore", operation)
+	}
+	return operation, nil
+}
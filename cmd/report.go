@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/hashicorp/vault-client-go"
+	"github.com/urfave/cli/v2"
+	"github.com/zduymz/hs-vault/metrics"
+	"log"
+	"path"
+)
+
+// runMetrics are shared by every run of the process, the daemon serves them on /metrics
+var runMetrics = metrics.New()
+
+// countAPIErrors counts API errors and retries of the client in the metrics of the job
+func countAPIErrors(c *cli.Context) vault.ClientOption {
+	return func(config *vault.ClientConfiguration) error {
+		retry := &config.RetryConfiguration
+		retry.CheckRetry = runMetrics.CheckRetry(c.String(FlagJob), retry.CheckRetry)
+		retry.Backoff = runMetrics.Backoff(c.String(FlagJob), retry.Backoff)
+		return nil
+	}
+}
+
+// finishRun logs the outcome of the run and writes the report and metrics files when requested,
+// run is nil when it failed before starting. It returns err, or the error writing them, with exit
+// code 2 when the run partially succeeded, 1 otherwise
+func finishRun(c *cli.Context, operation string, run *metrics.Run, err error) error {
+	if run == nil {
+		run = runMetrics.StartRun(c.String(FlagJob), operation)
+		run.Finish(err, false)
+	}
+
+	log.Printf("%v %v: %d engines, %d keys in %.1fs", run.Operation, run.Status, len(run.Engines), run.Keys, run.Duration)
+	for _, e := range run.Engines {
+		for _, k := range e.FailedKeys {
+			log.Printf("Skipped key '%v' of '%v': %v", k.Key, path.Join(e.Namespace, e.Path), k.Error)
+		}
+	}
+
+	if f := c.String(FlagReport); f != "" {
+		if werr := run.WriteReport(f); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	if f := c.String(FlagMetricsFile); f != "" {
+		if werr := runMetrics.WriteTextfile(f); werr != nil && err == nil {
+			err = werr
+		}
+	}
+
+	if err == nil {
+		return nil
+	}
+	if run.Status == metrics.StatusPartial {
+		return cli.Exit(err, 2)
+	}
+	return cli.Exit(err, 1)
+}
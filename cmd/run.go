@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"github.com/zduymz/hs-vault/archive"
+	"github.com/zduymz/hs-vault/storage"
+	"os"
+	"path"
+	"time"
+)
+
+// backupStorage returns where the run writes and the storage keeping runs. Runs for object storage
+// are written into a temporary directory, a new run directory of dest is written when runs are kept
+// or timestamped, dest itself otherwise
+func backupStorage(c *cli.Context) (string, storage.Storage, error) {
+	dest := c.String(FlagDest)
+	if storage.IsRemote(dest) {
+		s, err := storage.New(dest)
+		return "", s, err
+	}
+	if retention(c).Enabled() || c.Bool(FlagTimestamped) {
+		return path.Join(dest, storage.NewRunID(time.Now())), &storage.Local{Dir: dest}, nil
+	}
+	return dest, nil, nil
+}
+
+// retention returns the retention of runs set by --keep and --keep-daily, --keep-weekly, --keep-monthly
+func retention(c *cli.Context) storage.Retention {
+	return storage.Retention{
+		Keep:    c.Int(FlagKeep),
+		Daily:   c.Int(FlagKeepDaily),
+		Weekly:  c.Int(FlagKeepWeekly),
+		Monthly: c.Int(FlagKeepMonthly),
+	}
+}
+
+// restoreStorage returns the backup to restore, the last run is restored when source holds runs.
+// Runs compressed or encrypted without retention replace source by its archive
+func restoreStorage(c *cli.Context) (string, storage.Storage, error) {
+	source := c.String(FlagSource)
+	if storage.IsRemote(source) {
+		s, err := storage.New(source)
+		return "", s, err
+	}
+
+	if _, err := os.Stat(source); os.IsNotExist(err) {
+		for _, ext := range []string{archive.EncryptedExtension, archive.Extension} {
+			if _, err := os.Stat(source + ext); err == nil {
+				return source + ext, nil, nil
+			}
+		}
+		return "", nil, fmt.Errorf("backup '%v' not found", source)
+	}
+
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		local := &storage.Local{Dir: source}
+		runs, err := local.List(context.Background())
+		if err != nil {
+			return "", nil, err
+		}
+		if len(runs) > 0 {
+			return "", local, nil
+		}
+	}
+	return source, nil, nil
+}
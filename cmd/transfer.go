@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/vault-client-go"
+	"github.com/urfave/cli/v2"
+	"github.com/zduymz/hs-vault"
+	"github.com/zduymz/hs-vault/backends"
+	"github.com/zduymz/hs-vault/transfer"
+	"log"
+	"path"
+)
+
+// export converts a kv engine backup into portable files
+func export(c *cli.Context) error {
+	source := hsvault.NamespaceDir(c.String(FlagSource), c.String(FlagNamespace))
+	backups, err := hsvault.ListBackupEngines(source)
+	if err != nil {
+		return err
+	}
+
+	key := c.String(FlagPath)
+	engineType, ok := backups[key]
+	if !ok {
+		return fmt.Errorf("engine with path '%v' not found in '%v'", key, source)
+	}
+
+	secrets, err := transfer.ReadBackup(path.Join(source, fmt.Sprintf("%v.%v", key, engineType)), backends.EngineType(engineType))
+	if err != nil {
+		return err
+	}
+	secrets = secrets.Filter(c.StringSlice(FlagFilter))
+
+	format := transfer.Format(c.String(FlagFormat))
+	if format == transfer.DotenvFormat && c.String(FlagOut) == "-" {
+		return fmt.Errorf("dotenv export needs an output directory")
+	}
+
+	if err := transfer.Export(secrets, format, c.String(FlagOut)); err != nil {
+		return err
+	}
+	if c.String(FlagOut) != "-" {
+		log.Printf("Exported %d secrets of '%v' to '%v'", len(secrets), key, c.String(FlagOut))
+	}
+	return nil
+}
+
+// importSecrets writes exported files or other tools' formats into a kv engine
+func importSecrets(c *cli.Context) error {
+	secrets, err := transfer.ReadTemplate(c.String(FlagFile), transfer.Format(c.String(FlagFormat)), c.String(FlagPathTemplate))
+	if err != nil {
+		return err
+	}
+	secrets = secrets.Filter(c.StringSlice(FlagFilter))
+
+	client, logout := getVaultClient(c)
+	defer logout()
+	if c.IsSet(FlagNamespace) {
+		if err := client.SetNamespace(c.String(FlagNamespace)); err != nil {
+			return err
+		}
+	}
+
+	w, err := secretWriter(c, client, c.String(FlagPath))
+	if err != nil {
+		return err
+	}
+
+	if err := transfer.Import(context.Background(), w, secrets); err != nil {
+		return err
+	}
+	log.Printf("Imported %d secrets into '%v'", len(secrets), c.String(FlagPath))
+	return nil
+}
+
+// secretWriter returns the kv engine mounted at key
+func secretWriter(c *cli.Context, client *vault.Client, key string) (backends.SecretWriter, error) {
+	engines, err := hsvault.ListSecretsEngines(context.Background(), client)
+	if err != nil {
+		return nil, err
+	}
+
+	engine, ok := engines[key]
+	if !ok {
+		return nil, fmt.Errorf("engine with path '%v' not found", key)
+	}
+
+	se, err := hsvault.NewEngine(client, key, engine, &backends.Options{LogLevel: c.String(FlagLogLevel)})
+	if err != nil {
+		return nil, err
+	}
+	w, ok := se.(backends.SecretWriter)
+	if !ok {
+		return nil, fmt.Errorf("engine with path '%v' is not a kv engine", key)
+	}
+	return w, nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/vault-client-go"
+	"github.com/urfave/cli/v2"
+	"github.com/zduymz/hs-vault"
+	"github.com/zduymz/hs-vault/backends"
+	"log"
+	"os"
+	"path"
+	"sort"
+)
+
+// verifyReport counts secrets checked by verify and the problems found
+type verifyReport struct {
+	Checked   int
+	Corrupted int
+	Missing   int
+	Drifted   int
+}
+
+func verify(c *cli.Context) error {
+	// live verification is the only part talking to Vault
+	var client *vault.Client
+	if c.Bool(FlagLive) {
+		var logout func()
+		client, logout = getVaultClient(c)
+		defer logout()
+		if c.IsSet(FlagNamespace) {
+			if err := client.SetNamespace(c.String(FlagNamespace)); err != nil {
+				log.Fatalln(err)
+			}
+		}
+	}
+
+	report := &verifyReport{}
+	if err := verifyNamespace(c, client, c.String(FlagNamespace), c.String(FlagSource), report); err != nil {
+		log.Fatalln(err)
+	}
+
+	log.Printf("Checked %d secrets: %d corrupted, %d missing, %d drifted",
+		report.Checked, report.Corrupted, report.Missing, report.Drifted)
+
+	if report.Corrupted+report.Missing+report.Drifted > 0 {
+		return fmt.Errorf("backup verification failed")
+	}
+	return nil
+}
+
+// verifyNamespace verifies engine backups found in source and its children in source/namespaces/<name>,
+// secrets are compared with Vault too when client is set
+func verifyNamespace(c *cli.Context, client *vault.Client, namespace, source string, report *verifyReport) error {
+	backups, err := hsvault.ListBackupEngines(source)
+	if err != nil {
+		return err
+	}
+
+	var engines map[string]hsvault.Mount
+	var rawAccessible bool
+	if client != nil {
+		if engines, err = hsvault.ListMounts(context.Background(), client, namespace); err != nil {
+			return err
+		}
+		rawAccessible = hsvault.RawAccessible(context.Background(), client)
+	}
+
+	var keys []string
+	for key := range backups {
+		if c.IsSet(FlagPath) && key != c.String(FlagPath) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		dir := path.Join(source, fmt.Sprintf("%v.%v", key, backups[key]))
+		index, err := backends.ReadChecksumIndex(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				log.Printf("No checksum index found for '%v', skip verify", dir)
+				continue
+			}
+			return err
+		}
+
+		var live backends.LiveChecksummer
+		if client != nil {
+			if engine, ok := engines[key]; ok && !diffable(key, engine) {
+				log.Printf("Engine with path '%v' does not support live verify, skip", key)
+			} else if ok {
+				se, err := hsvault.NewEngine(client, key, engine, &backends.Options{
+					LogLevel:      c.String(FlagLogLevel),
+					RawAccessible: rawAccessible,
+				})
+				if err != nil {
+					return err
+				}
+				live, _ = se.(backends.LiveChecksummer)
+			} else {
+				log.Printf("Engine with path '%v' not found, skip live verify", key)
+			}
+		}
+
+		if err := verifyIndex(dir, index, live, report); err != nil {
+			return err
+		}
+	}
+
+	if c.IsSet(FlagPath) {
+		return nil
+	}
+
+	dirs, err := os.ReadDir(path.Join(source, "namespaces"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+
+		ns := path.Join(namespace, d.Name())
+		var nc *vault.Client
+		if client != nil {
+			if nc, err = hsvault.NamespaceClient(client, ns); err != nil {
+				return err
+			}
+		}
+
+		log.Printf("Verify namespace '%v'", ns)
+		if err := verifyNamespace(c, nc, ns, path.Join(source, "namespaces", d.Name()), report); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// diffable reports whether the engine mounted at key records where its data comes from
+func diffable(key string, mount hsvault.Mount) bool {
+	reg, ok := hsvault.Lookup(key, mount)
+	return ok && reg.Capabilities.Diff
+}
+
+// verifyIndex hashes every secret of the index again from dir, and from Vault when live is set
+func verifyIndex(dir string, index *backends.ChecksumIndex, live backends.LiveChecksummer, report *verifyReport) error {
+	for _, cs := range index.Checksums {
+		report.Checked++
+
+		name := path.Join(dir, cs.File)
+		if cs.Key != "" {
+			name = fmt.Sprintf("%v#%v", name, cs.Key)
+		}
+
+		sum, err := backends.FileChecksum(dir, cs)
+		switch {
+		case os.IsNotExist(err):
+			log.Printf("Missing: '%v'", name)
+			report.Missing++
+			continue
+		case err != nil:
+			log.Printf("Corrupted: '%v': %v", name, err)
+			report.Corrupted++
+			continue
+		case sum != cs.SHA256:
+			log.Printf("Corrupted: '%v': checksum mismatch", name)
+			report.Corrupted++
+			continue
+		}
+
+		if live == nil || cs.Source == "" {
+			continue
+		}
+
+		current, err := live.LiveChecksum(context.Background(), cs)
+		if err != nil {
+			if errors.Is(err, backends.ErrNoLiveSource) {
+				continue
+			}
+			return err
+		}
+
+		switch current {
+		case "":
+			log.Printf("Drift: '%v' was deleted from '%v'", name, cs.Source)
+			report.Drifted++
+		case cs.SHA256:
+		default:
+			log.Printf("Drift: '%v' changed in '%v'", name, cs.Source)
+			report.Drifted++
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"github.com/hashicorp/hcl"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path"
+)
+
+// Config is a file describing backup and restore jobs, HCL or YAML
+type Config struct {
+	Jobs []Job `hcl:"job" yaml:"jobs"`
+}
+
+// Vault is the connection of a job, the token is read from the environment unless auth is set
+type Vault struct {
+	Address    string `hcl:"address" yaml:"address"`
+	CACert     string `hcl:"ca_cert" yaml:"ca_cert"`
+	SkipVerify bool   `hcl:"skip_verify" yaml:"skip_verify"`
+	Auth       Auth   `hcl:"auth" yaml:"auth"`
+}
+
+// Auth logs the tool in, secrets are read from files or from VAULT_SECRET_ID and VAULT_PASSWORD
+type Auth struct {
+	Method       string `hcl:"method" yaml:"method"`
+	Mount        string `hcl:"mount" yaml:"mount"`
+	TokenFile    string `hcl:"token_file" yaml:"token_file"`
+	RoleID       string `hcl:"role_id" yaml:"role_id"`
+	RoleIDFile   string `hcl:"role_id_file" yaml:"role_id_file"`
+	SecretIDFile string `hcl:"secret_id_file" yaml:"secret_id_file"`
+	Role         string `hcl:"role" yaml:"role"`
+	JWTFile      string `hcl:"jwt_file" yaml:"jwt_file"`
+	Username     string `hcl:"username" yaml:"username"`
+	PasswordFile string `hcl:"password_file" yaml:"password_file"`
+}
+
+// Encryption encrypts backups with AES-256-GCM, the key is 32 bytes, raw or base64 encoded
+type Encryption struct {
+	KeyFile string `hcl:"key_file" yaml:"key_file"`
+	// KeyEnv names the environment variable holding the base64 encoded key
+	KeyEnv string `hcl:"key_env" yaml:"key_env"`
+}
+
+// Retention keeps the last runs of a job in its destination, and the last run of each
+// of the last days, weeks and months
+type Retention struct {
+	Keep    int `hcl:"keep" yaml:"keep"`
+	Daily   int `hcl:"daily" yaml:"daily"`
+	Weekly  int `hcl:"weekly" yaml:"weekly"`
+	Monthly int `hcl:"monthly" yaml:"monthly"`
+}
+
+// Job is one backup or restore run, every field maps to a command line flag
+type Job struct {
+	Name                    string     `hcl:",key" yaml:"name"`
+	Schedule                string     `hcl:"schedule" yaml:"schedule"`
+	Vault                   Vault      `hcl:"vault" yaml:"vault"`
+	Namespace               string     `hcl:"namespace" yaml:"namespace"`
+	RecursiveNamespaces     bool       `hcl:"recursive_namespaces" yaml:"recursive_namespaces"`
+	Include                 []string   `hcl:"include" yaml:"include"`
+	Exclude                 []string   `hcl:"exclude" yaml:"exclude"`
+	Destination             string     `hcl:"destination" yaml:"destination"`
+	Encryption              Encryption `hcl:"encryption" yaml:"encryption"`
+	Compression             string     `hcl:"compression" yaml:"compression"`
+	Retention               Retention  `hcl:"retention" yaml:"retention"`
+	Concurrency             int        `hcl:"concurrency" yaml:"concurrency"`
+	Raw                     bool       `hcl:"raw" yaml:"raw"`
+	ApproleSecretIDs        bool       `hcl:"approle_secret_ids" yaml:"approle_secret_ids"`
+	AuditRewrite            []string   `hcl:"audit_rewrite" yaml:"audit_rewrite"`
+	RemapPath               []string   `hcl:"remap_path" yaml:"remap_path"`
+	TransitRenumberVersions bool       `hcl:"transit_renumber_versions" yaml:"transit_renumber_versions"`
+	Verify                  bool       `hcl:"verify" yaml:"verify"`
+	LogLevel                string     `hcl:"log_level" yaml:"log_level"`
+	Report                  string     `hcl:"report" yaml:"report"`
+	MetricsFile             string     `hcl:"metrics_file" yaml:"metrics_file"`
+	ContinueOnError         bool       `hcl:"continue_on_error" yaml:"continue_on_error"`
+	SkipPreflight           bool       `hcl:"skip_preflight" yaml:"skip_preflight"`
+}
+
+// Load reads a config file, .hcl files are HCL, .yaml, .yml and .json files are YAML
+func Load(f string) (*Config, error) {
+	content, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	switch path.Ext(f) {
+	case ".hcl":
+		if err := hcl.Unmarshal(content, &config); err != nil {
+			return nil, err
+		}
+	case ".yaml", ".yml", ".json":
+		if err := yaml.Unmarshal(content, &config); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown config file extension '%v', expected .hcl, .yaml, .yml or .json", path.Ext(f))
+	}
+
+	for _, job := range config.Jobs {
+		if err := job.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &config, nil
+}
+
+// Job returns the job by name, the only job when name is empty
+func (c *Config) Job(name string) (*Job, error) {
+	if name == "" {
+		if len(c.Jobs) != 1 {
+			return nil, fmt.Errorf("config has %d jobs, select one with --job", len(c.Jobs))
+		}
+		return &c.Jobs[0], nil
+	}
+
+	for i := range c.Jobs {
+		if c.Jobs[i].Name == name {
+			return &c.Jobs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("job '%v' not found", name)
+}
+
+func (j *Job) Validate() error {
+	if j.Name == "" {
+		return fmt.Errorf("job without name")
+	}
+	switch j.Compression {
+	case "", "none", "tar.gz":
+	default:
+		return fmt.Errorf("job '%v': unknown compression '%v', expected tar.gz", j.Name, j.Compression)
+	}
+	r := j.Retention
+	if j.Concurrency < 0 || r.Keep < 0 || r.Daily < 0 || r.Weekly < 0 || r.Monthly < 0 {
+		return fmt.Errorf("job '%v': concurrency and retention must not be negative", j.Name)
+	}
+	if j.Schedule != "" {
+		if _, err := cron.ParseStandard(j.Schedule); err != nil {
+			return fmt.Errorf("job '%v': schedule: %w", j.Name, err)
+		}
+	}
+	return nil
+}
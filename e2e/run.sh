@@ -12,6 +12,10 @@ docker run -d --name vault2 --cap-add=IPC_LOCK -e VAULT_DEV_ROOT_TOKEN_ID=root \
   -e VAULT_DEV_LISTEN_ADDRESS=0.0.0.0:8200 -p8202:8200 \
   hashicorp/vault 2>/dev/null || docker start vault2
 
+echo "Start MinIO"
+docker run -d --name minio -e MINIO_ROOT_USER=minio -e MINIO_ROOT_PASSWORD=minio-secret \
+  -p9000:9000 minio/minio server /data 2>/dev/null || docker start minio
+
 FAILURES=""
 for TEST_FILE in ./e2e/test_*.sh; do
     echo
@@ -23,7 +27,7 @@ for TEST_FILE in ./e2e/test_*.sh; do
     fi
 done
 
-docker stop vault1 vault2
+docker stop vault1 vault2 minio
 
 if [[ -n "$FAILURES" ]]; then
     echo
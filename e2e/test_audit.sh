@@ -0,0 +1,11 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault audit enable -path=audit1 file file_path=/tmp/vault-audit.log > /dev/null
+
+./dist/hs-vault backup -p sys/audit -d /tmp
+
+export VAULT_ADDR="http://localhost:8202"
+./dist/hs-vault restore -p sys/audit -s /tmp/sys/audit.audit --audit-rewrite /tmp=/var/tmp
+
+RESULT=$(vault audit list -format=json | jq -r '.["audit1/"].options.file_path')
+./e2e/verify.sh "$RESULT" "/var/tmp/vault-audit.log"
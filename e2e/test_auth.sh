@@ -0,0 +1,31 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault auth enable approle
+vault write auth/approle/role/role1 token_policies=default,p1 secret_id_ttl=10m > /dev/null
+vault write auth/approle/role/role1/role-id role_id=role1-id > /dev/null
+vault write auth/token/roles/role1 allowed_policies=p1 orphan=true > /dev/null
+vault auth enable kubernetes
+vault write auth/kubernetes/config kubernetes_host=https://kubernetes.example.com:6443 disable_local_ca_jwt=true > /dev/null
+vault write auth/kubernetes/role/app bound_service_account_names=app bound_service_account_namespaces=default token_policies=p1 > /dev/null
+
+./dist/hs-vault backup -p auth/approle -d /tmp
+./dist/hs-vault backup -p auth/token -d /tmp
+./dist/hs-vault backup -p auth/kubernetes -d /tmp
+
+export VAULT_ADDR="http://localhost:8202"
+vault auth enable approle
+./dist/hs-vault restore -p auth/approle -s /tmp/auth/approle.approle
+./dist/hs-vault restore -p auth/token -s /tmp/auth/token.token
+vault auth enable kubernetes
+./dist/hs-vault restore -p auth/kubernetes -s /tmp/auth/kubernetes.kubernetes
+
+RESULT=$(vault read -field=role_id auth/approle/role/role1/role-id)
+./e2e/verify.sh "$RESULT" "role1-id"
+RESULT=$(vault read -format=json auth/approle/role/role1 | jq -r '.data.token_policies | join(",")')
+./e2e/verify.sh "$RESULT" "default,p1"
+RESULT=$(vault read -field=orphan auth/token/roles/role1)
+./e2e/verify.sh "$RESULT" "true"
+RESULT=$(vault read -field=kubernetes_host auth/kubernetes/config)
+./e2e/verify.sh "$RESULT" "https://kubernetes.example.com:6443"
+RESULT=$(vault read -format=json auth/kubernetes/role/app | jq -r '.data.bound_service_account_names | join(",")')
+./e2e/verify.sh "$RESULT" "app"
@@ -0,0 +1,45 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault secrets enable kubernetes
+vault write kubernetes/config kubernetes_host=https://kubernetes.example.com:6443 \
+  service_account_jwt=sa-jwt disable_local_ca_jwt=true > /dev/null
+vault write kubernetes/roles/reader allowed_kubernetes_namespaces=default \
+  kubernetes_role_name=view token_default_ttl=1h > /dev/null
+vault secrets enable ldap
+vault write ldap/config binddn=cn=admin,dc=example,dc=com bindpass=admin-pass \
+  url=ldaps://ldap.example.com schema=openldap > /dev/null
+vault write ldap/role/dynamic creation_ldif=@<(echo "dn: cn={{.Username}},dc=example,dc=com") \
+  deletion_ldif=@<(echo "dn: cn={{.Username}},dc=example,dc=com") default_ttl=1h > /dev/null
+vault secrets enable azure
+vault write azure/config subscription_id=sub-id tenant_id=tenant-id client_id=client-id \
+  client_secret=client-secret > /dev/null
+vault secrets enable gcp
+vault write gcp/config ttl=3600 credentials='{"type":"service_account","project_id":"example","client_email":"vault@example.iam.gserviceaccount.com"}' > /dev/null
+
+./dist/hs-vault backup -p kubernetes -d /tmp
+./dist/hs-vault backup -p ldap -d /tmp
+./dist/hs-vault backup -p azure -d /tmp
+./dist/hs-vault backup -p gcp -d /tmp
+
+export VAULT_ADDR="http://localhost:8202"
+vault secrets enable kubernetes
+vault secrets enable ldap
+vault secrets enable azure
+vault secrets enable gcp
+./dist/hs-vault restore -p kubernetes -s /tmp/kubernetes.kubernetes
+./dist/hs-vault restore -p ldap -s /tmp/ldap.ldap
+./dist/hs-vault restore -p azure -s /tmp/azure.azure
+./dist/hs-vault restore -p gcp -s /tmp/gcp.gcp
+
+RESULT=$(vault read -field=kubernetes_host kubernetes/config)
+./e2e/verify.sh "$RESULT" "https://kubernetes.example.com:6443"
+RESULT=$(vault read -field=kubernetes_role_name kubernetes/roles/reader)
+./e2e/verify.sh "$RESULT" "view"
+RESULT=$(vault read -field=url ldap/config)
+./e2e/verify.sh "$RESULT" "ldaps://ldap.example.com"
+RESULT=$(vault read -field=default_ttl ldap/role/dynamic)
+./e2e/verify.sh "$RESULT" "3600"
+RESULT=$(vault read -field=tenant_id azure/config)
+./e2e/verify.sh "$RESULT" "tenant-id"
+RESULT=$(vault read -field=ttl gcp/config)
+./e2e/verify.sh "$RESULT" "3600"
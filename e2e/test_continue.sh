@@ -0,0 +1,71 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault secrets enable -path=kv-continue -version=1 kv
+vault kv put kv-continue/a k=1 > /dev/null
+vault kv put kv-continue/denied k=2 > /dev/null
+vault kv put kv-continue/b k=3 > /dev/null
+vault policy write hs-vault-continue - > /dev/null <<POLICY
+path "*" {
+  capabilities = ["read", "list"]
+}
+path "kv-continue/denied" {
+  capabilities = ["deny"]
+}
+POLICY
+TOKEN=$(vault token create -policy=hs-vault-continue -field=token)
+
+# the run stops at the first error by default
+rm -rf /tmp/continue /tmp/continue.json
+VAULT_TOKEN="$TOKEN" ./dist/hs-vault backup -p kv-continue -d /tmp/continue --report /tmp/continue.json
+./e2e/verify.sh "$?" "1"
+
+RESULT=$(jq -r '.status' /tmp/continue.json)
+./e2e/verify.sh "$RESULT" "failed"
+
+# the denied key is skipped and the run is partial
+rm -rf /tmp/continue /tmp/continue.json
+VAULT_TOKEN="$TOKEN" ./dist/hs-vault backup -p kv-continue -d /tmp/continue --report /tmp/continue.json --continue-on-error
+./e2e/verify.sh "$?" "2"
+
+RESULT=$(jq -r '.status' /tmp/continue.json)
+./e2e/verify.sh "$RESULT" "partial"
+
+RESULT=$(jq -r '.engines[0].failed_keys[0].key' /tmp/continue.json)
+./e2e/verify.sh "$RESULT" "denied"
+
+RESULT=$(jq -r '.engines[0].keys' /tmp/continue.json)
+./e2e/verify.sh "$RESULT" "2"
+
+# the partial backup restores the other keys
+vault kv delete kv-continue/a > /dev/null
+./dist/hs-vault restore -p kv-continue -s /tmp/continue
+RESULT=$(vault kv get -field=k kv-continue/a)
+./e2e/verify.sh "$RESULT" "1"
+
+# a denied raw write fails the key instead of being ignored
+vault auth enable -path=userpass-continue userpass
+vault write auth/userpass-continue/users/alice password=secret > /dev/null
+rm -rf /tmp/continue-raw /tmp/continue-raw.json
+./dist/hs-vault backup -p auth/userpass-continue -d /tmp/continue-raw
+# password hashes are restored through sys/raw
+mkdir -p /tmp/continue-raw/auth/userpass-continue.userpass/user
+echo -n "e30=" > /tmp/continue-raw/auth/userpass-continue.userpass/user/alice
+vault policy write hs-vault-continue-raw - > /dev/null <<POLICY
+path "*" {
+  capabilities = ["create", "read", "update", "list", "sudo"]
+}
+path "sys/raw/*" {
+  capabilities = ["deny"]
+}
+POLICY
+RAW_TOKEN=$(vault token create -policy=hs-vault-continue-raw -field=token)
+
+VAULT_TOKEN="$RAW_TOKEN" ./dist/hs-vault restore -p auth/userpass-continue -s /tmp/continue-raw \
+  --report /tmp/continue-raw.json --continue-on-error
+./e2e/verify.sh "$?" "2"
+
+RESULT=$(jq -r '.status' /tmp/continue-raw.json)
+./e2e/verify.sh "$RESULT" "partial"
+
+RESULT=$(jq -r '.engines[0].failed_keys[0].key' /tmp/continue-raw.json)
+./e2e/verify.sh "$RESULT" "user/alice"
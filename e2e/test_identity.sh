@@ -0,0 +1,20 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault auth enable userpass
+vault write identity/entity name=alice policies=p1 > /dev/null
+ENTITY_ID=$(vault read -field=id identity/entity/name/alice)
+ACCESSOR=$(vault auth list -format=json | jq -r '.["userpass/"].accessor')
+vault write identity/entity-alias name=alice canonical_id=$ENTITY_ID mount_accessor=$ACCESSOR > /dev/null
+vault write identity/group name=team policies=p2 member_entity_ids=$ENTITY_ID > /dev/null
+
+./dist/hs-vault backup -p identity -d /tmp
+
+export VAULT_ADDR="http://localhost:8202"
+vault auth enable userpass
+./dist/hs-vault restore -p identity -s /tmp/identity.identity
+
+ENTITY_ID=$(vault read -field=id identity/entity/name/alice)
+RESULT=$(vault read -format=json identity/entity/name/alice | jq -r '.data.aliases[0].mount_path')
+./e2e/verify.sh "$RESULT" "auth/userpass/"
+RESULT=$(vault read -format=json identity/group/name/team | jq -r '.data.member_entity_ids[0]')
+./e2e/verify.sh "$RESULT" "$ENTITY_ID"
@@ -0,0 +1,39 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8202"
+vault secrets enable -path=kv-migrate -version=2 kv
+
+cat > /tmp/secrets.k8s.yaml <<MANIFEST
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: prod
+type: Opaque
+data:
+  password: c2VjcmV0
+stringData:
+  user: admin
+MANIFEST
+
+cat > /tmp/secrets.dec.yaml <<TREE
+app:
+  api:
+    token: abc
+sops:
+  version: 3.8.0
+TREE
+
+echo '{"secret/": {"team/": {"cache": {"url": "redis://cache"}}}}' > /tmp/secrets.export.json
+
+./dist/hs-vault import -p kv-migrate -f /tmp/secrets.k8s.yaml -o kubernetes --path-template 'k8s/{{.Namespace}}/{{.Name}}'
+./dist/hs-vault import -p kv-migrate -f /tmp/secrets.dec.yaml -o sops
+./dist/hs-vault import -p kv-migrate -f /tmp/secrets.export.json -o vault-export --path-template '{{trimPrefix "secret/" .Key}}'
+
+RESULT=$(vault kv get -field=password kv-migrate/k8s/prod/db)
+./e2e/verify.sh "$RESULT" "secret"
+RESULT=$(vault kv get -field=user kv-migrate/k8s/prod/db)
+./e2e/verify.sh "$RESULT" "admin"
+RESULT=$(vault kv get -field=token kv-migrate/app/api)
+./e2e/verify.sh "$RESULT" "abc"
+RESULT=$(vault kv get -field=url kv-migrate/team/cache)
+./e2e/verify.sh "$RESULT" "redis://cache"
@@ -0,0 +1,17 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault secrets enable -path=kv-inspect -version=2 kv
+vault kv put kv-inspect/app/db password=first > /dev/null
+vault kv put kv-inspect/app/db password=second > /dev/null
+
+rm -rf /tmp/inspect
+./dist/hs-vault backup -p kv-inspect -d /tmp/inspect
+
+RESULT=$(./dist/hs-vault inspect -s /tmp/inspect -o json | jq -r '.[] | select(.path == "kv-inspect") | .type')
+./e2e/verify.sh "$RESULT" "kv2"
+RESULT=$(./dist/hs-vault inspect -s /tmp/inspect -p kv-inspect -o json | jq -r '.[0].key')
+./e2e/verify.sh "$RESULT" "app/db"
+RESULT=$(./dist/hs-vault inspect -s /tmp/inspect -p kv-inspect -k app/db -o json | jq -r '.data.password')
+./e2e/verify.sh "$RESULT" "********"
+RESULT=$(./dist/hs-vault inspect -s /tmp/inspect -p kv-inspect -k app/db --version 1 --show-secrets -o json | jq -r '.data.password')
+./e2e/verify.sh "$RESULT" "first"
@@ -0,0 +1,71 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault secrets enable -path=kv-job -version=1 kv
+vault kv put kv-job/app k=job > /dev/null
+vault secrets enable -path=kv-skip -version=1 kv
+
+export BACKUP_KEY=$(head -c 32 /dev/urandom | base64)
+rm -rf /tmp/jobs
+cat > /tmp/jobs.hcl <<CONFIG
+job "nightly" {
+  vault {
+    address = "http://localhost:8201"
+  }
+  include     = ["kv-*"]
+  exclude     = ["kv-skip"]
+  destination = "/tmp/jobs/nightly"
+  compression = "tar.gz"
+  encryption {
+    key_env = "BACKUP_KEY"
+  }
+  retention {
+    keep = 2
+  }
+  concurrency = 2
+}
+CONFIG
+
+for i in 1 2 3; do
+  ./dist/hs-vault backup --config /tmp/jobs.hcl --job nightly
+  sleep 1
+done
+
+RESULT=$(ls /tmp/jobs/nightly | grep -c ".tar.gz.enc")
+./e2e/verify.sh "$RESULT" "2"
+
+export VAULT_ADDR="http://localhost:8202"
+vault secrets enable -path=kv-job -version=1 kv
+./dist/hs-vault restore --config /tmp/jobs.hcl --job nightly --address http://localhost:8202
+
+RESULT=$(vault kv get -field=k kv-job/app)
+./e2e/verify.sh "$RESULT" "job"
+
+# compressed and encrypted runs without retention are restored from their archive
+cat > /tmp/jobs-adhoc.hcl <<CONFIG
+job "adhoc" {
+  include     = ["kv-job"]
+  destination = "/tmp/jobs/adhoc"
+  compression = "tar.gz"
+  encryption {
+    key_env = "BACKUP_KEY"
+  }
+}
+CONFIG
+
+export VAULT_ADDR="http://localhost:8201"
+./dist/hs-vault backup --config /tmp/jobs-adhoc.hcl --job adhoc --address http://localhost:8201
+
+RESULT=$(ls /tmp/jobs | grep -c "^adhoc.tar.gz.enc$")
+./e2e/verify.sh "$RESULT" "1"
+
+export VAULT_ADDR="http://localhost:8202"
+vault kv delete kv-job/app > /dev/null
+./dist/hs-vault restore --config /tmp/jobs-adhoc.hcl --job adhoc --address http://localhost:8202
+
+RESULT=$(vault kv get -field=k kv-job/app)
+./e2e/verify.sh "$RESULT" "job"
+
+# a missing backup fails the restore
+./dist/hs-vault restore -s /tmp/jobs/missing --address http://localhost:8202
+RESULT=$?
+./e2e/verify.sh "$RESULT" "1"
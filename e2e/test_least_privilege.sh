@@ -0,0 +1,32 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault secrets enable -path=kv-least -version=1 kv
+vault secrets enable -path=kv-least-other -version=1 kv
+vault kv put kv-least/a k=1 > /dev/null
+vault kv put kv-least-other/a k=2 > /dev/null
+
+./dist/hs-vault policy -p kv-least --out /tmp/least-backup.hcl
+vault policy write hs-vault-least-backup /tmp/least-backup.hcl > /dev/null
+./dist/hs-vault policy -p kv-least --operation restore --verify --out /tmp/least-restore.hcl
+vault policy write hs-vault-least-restore /tmp/least-restore.hcl > /dev/null
+
+RESULT=$(grep -c 'path "kv-least/\*"' /tmp/least-backup.hcl)
+./e2e/verify.sh "$RESULT" "1"
+
+BACKUP_TOKEN=$(vault token create -policy=hs-vault-least-backup -field=token)
+RESTORE_TOKEN=$(vault token create -policy=hs-vault-least-restore -field=token)
+
+rm -rf /tmp/least
+VAULT_TOKEN="$BACKUP_TOKEN" ./dist/hs-vault backup -p kv-least -d /tmp/least
+./e2e/verify.sh "$?" "0"
+
+# the token only covers the engine it was generated for
+VAULT_TOKEN="$BACKUP_TOKEN" ./dist/hs-vault backup -p kv-least-other -d /tmp/least
+./e2e/verify.sh "$?" "1"
+
+vault kv delete kv-least/a > /dev/null
+VAULT_TOKEN="$RESTORE_TOKEN" ./dist/hs-vault restore -p kv-least -s /tmp/least --verify
+./e2e/verify.sh "$?" "0"
+
+RESULT=$(vault kv get -field=k kv-least/a)
+./e2e/verify.sh "$RESULT" "1"
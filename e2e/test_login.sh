@@ -0,0 +1,31 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault secrets enable -path=kv-login -version=1 kv
+vault kv put kv-login/app k=login > /dev/null
+vault policy write hs-vault-login - > /dev/null <<POLICY
+path "*" {
+  capabilities = ["create", "read", "update", "delete", "list", "sudo"]
+}
+POLICY
+vault auth enable -path=approle-login approle
+vault write auth/approle-login/role/hs-vault token_policies=hs-vault-login token_ttl=30s token_max_ttl=2m > /dev/null
+ROLE_ID=$(vault read -field=role_id auth/approle-login/role/hs-vault/role-id)
+SECRET_ID=$(vault write -f -field=secret_id auth/approle-login/role/hs-vault/secret-id)
+ACCESSORS=$(vault list -format=json auth/token/accessors | jq length)
+
+rm -rf /tmp/login
+VAULT_TOKEN= VAULT_SECRET_ID="$SECRET_ID" ./dist/hs-vault backup -p kv-login -d /tmp/login \
+  --auth-method approle --auth-mount approle-login --role-id "$ROLE_ID"
+
+RESULT=$(ls /tmp/login | grep -c "kv-login.kv")
+./e2e/verify.sh "$RESULT" "1"
+
+# the approle token is revoked at the end
+RESULT=$(vault list -format=json auth/token/accessors | jq length)
+./e2e/verify.sh "$RESULT" "$ACCESSORS"
+
+echo -n root > /tmp/login-token
+VAULT_TOKEN= ./dist/hs-vault backup -p kv-login -d /tmp/login-file --auth-method token-file --token-file /tmp/login-token
+
+RESULT=$(ls /tmp/login-file | grep -c "kv-login.kv")
+./e2e/verify.sh "$RESULT" "1"
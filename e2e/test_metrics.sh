@@ -0,0 +1,23 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault secrets enable -path=kv-metrics -version=1 kv
+vault kv put kv-metrics/a k=1 > /dev/null
+vault kv put kv-metrics/b k=2 > /dev/null
+
+rm -rf /tmp/metrics /tmp/metrics.json /tmp/metrics.prom
+./dist/hs-vault backup -p kv-metrics -d /tmp/metrics --report /tmp/metrics.json --metrics-file /tmp/metrics.prom
+
+RESULT=$(jq -r '.engines[0].keys' /tmp/metrics.json)
+./e2e/verify.sh "$RESULT" "2"
+
+RESULT=$(grep -c '^hs_vault_last_success_timestamp_seconds{job="",operation="backup"}' /tmp/metrics.prom)
+./e2e/verify.sh "$RESULT" "1"
+
+# a failed run keeps the last success
+./dist/hs-vault backup -p kv-missing -d /tmp/metrics --report /tmp/metrics.json --metrics-file /tmp/metrics.prom
+
+RESULT=$(jq -r '.status' /tmp/metrics.json)
+./e2e/verify.sh "$RESULT" "failed"
+
+RESULT=$(grep -c '^hs_vault_last_success_timestamp_seconds{job="",operation="backup"}' /tmp/metrics.prom)
+./e2e/verify.sh "$RESULT" "1"
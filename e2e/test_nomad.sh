@@ -0,0 +1,29 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault secrets enable nomad
+vault write nomad/config/access address=http://nomad.example.com:4646 > /dev/null
+vault write nomad/config/lease ttl=3600 max_ttl=7200 > /dev/null
+vault write nomad/role/monitoring policies=readonly > /dev/null
+vault secrets enable consul
+vault write consul/config/access address=consul.example.com:8500 token=consul-token > /dev/null
+vault write consul/roles/ops consul_policies=ops > /dev/null
+
+./dist/hs-vault backup -p nomad -d /tmp
+./dist/hs-vault backup -p consul -d /tmp
+
+export VAULT_ADDR="http://localhost:8202"
+vault secrets enable nomad
+vault secrets enable consul
+./dist/hs-vault restore -p nomad -s /tmp/nomad.nomad
+./dist/hs-vault restore -p consul -s /tmp/consul.consul
+
+RESULT=$(vault read -field=address nomad/config/access)
+./e2e/verify.sh "$RESULT" "http://nomad.example.com:4646"
+RESULT=$(vault read -field=max_ttl nomad/config/lease)
+./e2e/verify.sh "$RESULT" "7200"
+RESULT=$(vault read -format=json nomad/role/monitoring | jq -r '.data.policies | join(",")')
+./e2e/verify.sh "$RESULT" "readonly"
+RESULT=$(vault read -field=address consul/config/access)
+./e2e/verify.sh "$RESULT" "consul.example.com:8500"
+RESULT=$(vault read -format=json consul/roles/ops | jq -r '.data.consul_policies | join(",")')
+./e2e/verify.sh "$RESULT" "ops"
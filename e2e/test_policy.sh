@@ -0,0 +1,21 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault policy write p1 - <<POLICY > /dev/null
+path "kv/*" {
+  capabilities = ["read", "list"]
+}
+POLICY
+vault write sys/policies/password/pw1 policy='length = 20
+rule "charset" {
+  charset = "abcdefghijklmnopqrstuvwxyz"
+}' > /dev/null
+
+./dist/hs-vault backup -p sys/policies -d /tmp
+
+export VAULT_ADDR="http://localhost:8202"
+./dist/hs-vault restore -p sys/policies -s /tmp/sys/policies.policy
+
+RESULT=$(vault policy read p1 | grep -c 'capabilities = \["read", "list"\]')
+./e2e/verify.sh "$RESULT" "1"
+RESULT=$(vault read -field=password sys/policies/password/pw1/generate | wc -c)
+./e2e/verify.sh "$RESULT" "20"
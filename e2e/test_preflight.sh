@@ -0,0 +1,43 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault secrets enable -path=kv-preflight -version=1 kv
+vault secrets enable -path=kv-preflight-denied -version=1 kv
+vault secrets enable -path=pki-preflight pki
+vault kv put kv-preflight/a k=1 > /dev/null
+vault policy write hs-vault-preflight - > /dev/null <<POLICY
+path "sys/mounts" {
+  capabilities = ["read"]
+}
+path "sys/auth" {
+  capabilities = ["read"]
+}
+path "kv-preflight/*" {
+  capabilities = ["read", "list"]
+}
+path "pki-preflight/*" {
+  capabilities = ["read", "list"]
+}
+POLICY
+TOKEN=$(vault token create -policy=hs-vault-preflight -field=token)
+
+VAULT_TOKEN="$TOKEN" ./dist/hs-vault preflight -p kv-preflight
+./e2e/verify.sh "$?" "0"
+
+RESULT=$(VAULT_TOKEN="$TOKEN" ./dist/hs-vault preflight -p kv-preflight --operation restore -o json | jq -r '.[] | select(.engine == "kv-preflight") | .missing')
+./e2e/verify.sh "$RESULT" "create,update"
+
+# the backup is refused before anything is read
+rm -rf /tmp/preflight
+VAULT_TOKEN="$TOKEN" ./dist/hs-vault backup -p kv-preflight-denied -d /tmp/preflight
+./e2e/verify.sh "$?" "1"
+
+RESULT=$(ls /tmp/preflight 2>/dev/null | grep -c "kv-preflight-denied")
+./e2e/verify.sh "$RESULT" "0"
+
+# pki is only backed up through sys/raw
+RESULT=$(VAULT_TOKEN="$TOKEN" ./dist/hs-vault preflight -p pki-preflight -o json | jq -r '.[] | select(.engine == "pki-preflight") | .status')
+./e2e/verify.sh "$RESULT" "missing"
+
+VAULT_TOKEN="$TOKEN" ./dist/hs-vault backup -p kv-preflight -d /tmp/preflight
+RESULT=$(ls /tmp/preflight | grep -c "kv-preflight.kv")
+./e2e/verify.sh "$RESULT" "1"
@@ -0,0 +1,15 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault secrets enable -path=quota-kv -version=1 kv
+vault write sys/quotas/rate-limit/rl1 path=quota-kv/ rate=100 interval=10s > /dev/null
+
+./dist/hs-vault backup -p sys/quotas -d /tmp
+
+export VAULT_ADDR="http://localhost:8202"
+vault secrets enable -path=quota-kv-new -version=1 kv
+./dist/hs-vault restore -p sys/quotas -s /tmp/sys/quotas.quota --remap-path quota-kv=quota-kv-new
+
+RESULT=$(vault read -field=path sys/quotas/rate-limit/rl1)
+./e2e/verify.sh "$RESULT" "quota-kv-new/"
+RESULT=$(vault read -field=rate sys/quotas/rate-limit/rl1)
+./e2e/verify.sh "$RESULT" "100"
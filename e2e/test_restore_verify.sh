@@ -0,0 +1,20 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault secrets enable -path=kv-readback -version=2 kv
+vault kv put kv-readback/key1 k=1 > /dev/null
+vault kv put kv-readback/key1 k=2 > /dev/null
+vault kv metadata put -max-versions=5 kv-readback/key1 > /dev/null
+vault secrets enable -path=ssh-readback ssh
+vault write ssh-readback/roles/otp key_type=otp default_user=ubuntu cidr_list=10.0.0.0/8 ttl=1h > /dev/null
+
+rm -rf /tmp/readback
+./dist/hs-vault backup -p kv-readback -d /tmp/readback
+./dist/hs-vault backup -p ssh-readback -d /tmp/readback
+
+export VAULT_ADDR="http://localhost:8202"
+vault secrets enable -path=kv-readback -version=2 kv
+vault secrets enable -path=ssh-readback ssh
+./dist/hs-vault restore -p kv-readback -s /tmp/readback/kv-readback.kv2 --verify
+./e2e/verify.sh "$?" "0"
+./dist/hs-vault restore -p ssh-readback -s /tmp/readback/ssh-readback.ssh --verify
+./e2e/verify.sh "$?" "0"
@@ -0,0 +1,23 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault secrets enable -path=kv-export -version=2 kv
+vault kv put kv-export/app/db password=secret > /dev/null
+vault kv put kv-export/app/api token=abc > /dev/null
+vault kv put kv-export/other/key k=1 > /dev/null
+
+rm -rf /tmp/export
+./dist/hs-vault backup -p kv-export -d /tmp/export
+./dist/hs-vault export -s /tmp/export -p kv-export --filter app -o yaml --out /tmp/export/secrets.yaml
+./dist/hs-vault export -s /tmp/export -p kv-export --filter 'other/*' -o dotenv --out /tmp/export/env
+
+export VAULT_ADDR="http://localhost:8202"
+vault secrets enable -path=kv-import -version=1 kv
+./dist/hs-vault import -p kv-import -f /tmp/export/secrets.yaml -o yaml
+./dist/hs-vault import -p kv-import -f /tmp/export/env -o dotenv
+
+RESULT=$(vault kv get -field=password kv-import/app/db)
+./e2e/verify.sh "$RESULT" "secret"
+RESULT=$(vault kv get -field=token kv-import/app/api)
+./e2e/verify.sh "$RESULT" "abc"
+RESULT=$(vault kv get -field=k kv-import/other/key)
+./e2e/verify.sh "$RESULT" "1"
@@ -5,6 +5,11 @@ vault write -f transit/keys/key1 > /dev/null
 export TRANSIT_SECRET_MSG1=$(vault write -field=ciphertext transit/encrypt/key1 plaintext=$(base64 <<< "this is first sky"))
 vault write -f transit/keys/key1/rotate > /dev/null
 export TRANSIT_SECRET_MSG2=$(vault write -field=ciphertext transit/encrypt/key1 plaintext=$(base64 <<< "this is second sky"))
+vault write transit/keys/key1/config deletion_allowed=true auto_rotate_period=24h > /dev/null
+vault write transit/cache-config size=500 > /dev/null
+vault write transit/keys/key2 exportable=true > /dev/null
+vault write -f transit/keys/key2/rotate > /dev/null
+export TRANSIT_SECRET_MSG4=$(vault write -field=ciphertext transit/encrypt/key2 plaintext=$(base64 <<< "this is byok sky"))
 
 ./dist/hs-vault backup -p transit -d /tmp
 
@@ -18,6 +23,19 @@ MSG2=$(vault write -field=plaintext transit/decrypt/key1 ciphertext=${TRANSIT_SE
 ./e2e/verify.sh "$MSG1" "this is first sky"
 ./e2e/verify.sh "$MSG2" "this is second sky"
 
+RESULT=$(vault read -field=deletion_allowed transit/keys/key1)
+./e2e/verify.sh "$RESULT" "true"
+RESULT=$(vault read -field=auto_rotate_period transit/keys/key1)
+./e2e/verify.sh "$RESULT" "86400"
+RESULT=$(vault read -field=size transit/cache-config)
+./e2e/verify.sh "$RESULT" "500"
+
+# key2 is exportable only, it is imported with the wrapping key
+MSG4=$(vault write -field=plaintext transit/decrypt/key2 ciphertext=${TRANSIT_SECRET_MSG4} | base64 -d)
+./e2e/verify.sh "$MSG4" "this is byok sky"
+RESULT=$(vault read -field=allow_plaintext_backup transit/keys/key2)
+./e2e/verify.sh "$RESULT" "false"
+
 vault write -f transit/keys/key1/rotate > /dev/null
 TRANSIT_SECRET_MSG3=$(vault write -field=ciphertext transit/encrypt/key1 plaintext=$(base64 <<< "this is third sky"))
 MSG3=$(vault write -field=plaintext transit/decrypt/key1 ciphertext=${TRANSIT_SECRET_MSG3} | base64 -d)
@@ -0,0 +1,19 @@
+export VAULT_TOKEN=root
+export VAULT_ADDR="http://localhost:8201"
+vault secrets enable -path=kv-verify -version=2 kv
+vault kv put kv-verify/key1 k=1 > /dev/null
+vault kv put kv-verify/key2 k=2 > /dev/null
+
+rm -rf /tmp/verify
+./dist/hs-vault backup -p kv-verify -d /tmp/verify
+
+./dist/hs-vault verify -s /tmp/verify --live > /dev/null 2>&1
+./e2e/verify.sh "$?" "0"
+
+vault kv put kv-verify/key1 k=changed > /dev/null
+RESULT=$(./dist/hs-vault verify -s /tmp/verify --live 2>&1 | grep -c "Drift")
+./e2e/verify.sh "$RESULT" "1"
+
+echo "corrupted" > /tmp/verify/kv-verify.kv2/file0.json
+./dist/hs-vault verify -s /tmp/verify > /dev/null 2>&1
+./e2e/verify.sh "$?" "1"
@@ -0,0 +1,444 @@
+// Package hsvault backs up and restores Vault secrets engines, auth methods and system components.
+// The hs-vault command is built on it, services can embed it to run backups in-process:
+//
+//	run, err := hsvault.Backup(ctx, client, hsvault.Config{Storage: s3, Retention: storage.Retention{Daily: 7}})
+package hsvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/vault-client-go"
+	"github.com/zduymz/hs-vault/archive"
+	"github.com/zduymz/hs-vault/backends"
+	"github.com/zduymz/hs-vault/metrics"
+	"github.com/zduymz/hs-vault/storage"
+	"go.uber.org/zap"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config selects what Backup and Restore process and where backups are kept
+type Config struct {
+	// Job names the run in metrics and reports
+	Job string
+	// Namespace is the namespace of the client, it is set on a copy of the client when not empty
+	Namespace string
+	// Recursive processes child namespaces too, they are stored in namespaces/<name>
+	Recursive bool
+	// Path selects a single engine, eg: kv or auth/approle, Include and Exclude are ignored then
+	Path string
+	// Include keeps engines whose path matches a glob, all by default, Exclude skips them
+	Include []string
+	Exclude []string
+	// Concurrency is the number of engines processed at the same time, 1 when not set
+	Concurrency int
+	// ContinueOnError skips failed keys and engines instead of stopping the run
+	ContinueOnError bool
+	// Preflight checks the capabilities of the token on every path of the run first, the run is refused
+	// when required ones are missing, see Preflight
+	Preflight bool
+
+	// Dir is where Backup writes the run, a temporary directory when empty and Storage is set.
+	// Restore reads it, a backup directory or archive, when Storage is nil
+	Dir string
+	// Storage keeps runs, Backup stores the run into it and Restore fetches the last one
+	Storage storage.Storage
+	// Retention prunes runs of Storage after a complete backup
+	Retention storage.Retention
+	// Compress packs the run into a tar.gz archive, runs are always compressed when encrypted
+	Compress bool
+	// EncryptionKey is the AES-256 key sealing the archive
+	EncryptionKey []byte
+
+	Base64Encode    bool
+	BackupSecretIDs bool
+	// AuditRewrites replaces the prefix of audit devices file_path or address on restore
+	AuditRewrites map[string]string
+	// PathRemap replaces mount paths referenced by restored configuration
+	PathRemap map[string]string
+	// TransitRenumberVersions imports trimmed transit keys with versions renumbered from 1, they are refused otherwise
+	TransitRenumberVersions bool
+	// Verify reads back restored keys, mismatches with the backup fail the restore
+	Verify bool
+
+	// Logger receives logs of the run and its engines, nothing is logged when nil
+	Logger *zap.Logger
+	// Metrics records engine and run metrics, a private registry is used when nil
+	Metrics *metrics.Metrics
+	// OnEngineStart is called before an engine is processed, engines may run concurrently
+	OnEngineStart func(e metrics.EngineResult)
+	// OnEngineDone is called with the result of each engine
+	OnEngineDone func(e metrics.EngineResult)
+}
+
+// runner runs a backup or restore of a config
+type runner struct {
+	Config
+	run    *metrics.Run
+	logger *zap.Logger
+	raw    bool
+}
+
+func newRunner(ctx context.Context, client *vault.Client, config Config, operation string) *runner {
+	r := &runner{Config: config, logger: config.Logger}
+	if r.logger == nil {
+		r.logger = zap.NewNop()
+	}
+	if r.Metrics == nil {
+		r.Metrics = metrics.New()
+	}
+	r.run = r.Metrics.StartRun(r.Job, operation)
+	r.raw = RawAccessible(ctx, client)
+	return r
+}
+
+// Backup backs up the engines selected by config, the run is stored when config has a storage.
+// With ContinueOnError, the run is stored even when engines failed. The returned run is finished,
+// its status tells a partial run from a failed one
+func Backup(ctx context.Context, client *vault.Client, config Config) (*metrics.Run, error) {
+	client, err := namespaced(client, config.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newRunner(ctx, client, config, "backup")
+	if err = r.preflight(ctx, client, "backup"); err == nil {
+		err = r.backup(ctx, client)
+	}
+	r.run.Finish(err, r.ContinueOnError)
+	return r.run, err
+}
+
+func (r *runner) backup(ctx context.Context, client *vault.Client) error {
+	dest := r.Dir
+	if dest == "" {
+		if r.Storage == nil {
+			return fmt.Errorf("a directory or a storage is required")
+		}
+		tmp, err := os.MkdirTemp("", "hs-vault-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		dest = path.Join(tmp, storage.NewRunID(time.Now()))
+	}
+
+	var err error
+	if r.Path != "" {
+		err = r.backupPath(ctx, client, dest)
+	} else if r.Recursive {
+		err = r.backupNamespace(ctx, client, r.Namespace, dest)
+	} else {
+		err = r.backupEngines(ctx, client, r.Namespace, dest)
+	}
+	if err != nil && !r.ContinueOnError {
+		return err
+	}
+
+	return errors.Join(err, r.store(ctx, dest, err == nil))
+}
+
+func (r *runner) backupPath(ctx context.Context, client *vault.Client, dest string) error {
+	mounts, err := ListMounts(ctx, client, r.Namespace)
+	if err != nil {
+		return err
+	}
+
+	mount, ok := mounts[r.Path]
+	if !ok {
+		return fmt.Errorf("engine with path '%v' not found", r.Path)
+	}
+	return r.backupEngine(ctx, client, r.Namespace, r.Path, mount, dest)
+}
+
+// backupEngines backs up every selected engine of the client namespace into dest
+func (r *runner) backupEngines(ctx context.Context, client *vault.Client, namespace, dest string) error {
+	mounts, err := ListMounts(ctx, client, namespace)
+	if err != nil {
+		return err
+	}
+	mounts = selectMounts(mounts, r.Include, r.Exclude)
+
+	var keys []string
+	for key := range mounts {
+		keys = append(keys, key)
+	}
+
+	return r.parallel(sorted(keys), func(key string) error {
+		return r.backupEngine(ctx, client, namespace, key, mounts[key], dest)
+	})
+}
+
+// backupEngine runs the backup, writes the checksum index of what was read and records the engine in the run
+func (r *runner) backupEngine(ctx context.Context, client *vault.Client, namespace, key string, mount Mount, dest string) (err error) {
+	result := r.start(namespace, key, mount)
+	var se backends.Engine
+	defer func() { r.done(result, se, 0, err) }()
+	defer func() { err = engineError("backup", namespace, key, mount, se, err) }()
+
+	se, err = NewEngine(client, key, mount, r.options(dest, ""))
+	if err != nil {
+		return err
+	}
+
+	if err := se.Backup(ctx); err != nil {
+		return err
+	}
+
+	if ci, ok := se.(backends.ChecksumIndexer); ok {
+		return ci.WriteChecksumIndex()
+	}
+	return nil
+}
+
+// store compresses and encrypts the run when requested, stores it and prunes old runs once complete
+func (r *runner) store(ctx context.Context, dir string, complete bool) error {
+	out := dir
+	if r.Compress || r.EncryptionKey != nil {
+		var err error
+		if out, err = archive.Pack(dir, r.EncryptionKey); err != nil {
+			return err
+		}
+	}
+
+	if r.Storage == nil {
+		r.logger.Info("Backup written", zap.String("path", out))
+		return nil
+	}
+
+	if err := r.Storage.Store(ctx, out); err != nil {
+		return err
+	}
+	r.logger.Info("Backup stored", zap.String("storage", r.Storage.String()), zap.String("run", path.Base(out)))
+
+	// a partial run must not make complete ones expire
+	if !complete {
+		return nil
+	}
+	removed, err := storage.Prune(ctx, r.Storage, r.Retention)
+	for _, run := range removed {
+		r.logger.Info("Removed old backup", zap.String("run", run))
+	}
+	return err
+}
+
+// Restore restores the engines selected by config from the last run of its storage, or from its
+// directory. Verification mismatches fail the restore
+func Restore(ctx context.Context, client *vault.Client, config Config) (*metrics.Run, error) {
+	client, err := namespaced(client, config.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newRunner(ctx, client, config, "restore")
+	if err = r.preflight(ctx, client, "restore"); err == nil {
+		err = r.restore(ctx, client)
+	}
+	r.run.Finish(err, r.ContinueOnError)
+	return r.run, err
+}
+
+func (r *runner) restore(ctx context.Context, client *vault.Client) error {
+	source, cleanup, err := r.source(ctx)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if r.Path != "" {
+		return r.restorePath(ctx, client, source)
+	}
+	if r.Recursive {
+		return r.restoreNamespace(ctx, client, r.Namespace, source)
+	}
+	return r.restoreEngines(ctx, client, r.Namespace, source)
+}
+
+// source returns the directory to restore from, the last run of the storage is fetched and archives
+// are extracted into a temporary directory, cleanup removes them
+func (r *runner) source(ctx context.Context) (string, func(), error) {
+	source := r.Dir
+	cleanup := func() {}
+
+	if r.Storage != nil {
+		run, err := storage.Latest(ctx, r.Storage)
+		if err != nil {
+			return "", cleanup, err
+		}
+		if run == "" {
+			return "", cleanup, fmt.Errorf("no backup found in '%v'", r.Storage)
+		}
+		r.logger.Info("Restore from the last backup", zap.String("storage", r.Storage.String()), zap.String("run", run))
+		if source, cleanup, err = r.Storage.Fetch(ctx, run); err != nil {
+			return "", cleanup, err
+		}
+	}
+
+	if _, err := os.Stat(source); err != nil {
+		cleanup()
+		return "", func() {}, fmt.Errorf("backup '%v' not found: %w", source, err)
+	}
+
+	if !archive.IsArchive(source) {
+		return source, cleanup, nil
+	}
+
+	tmp, err := os.MkdirTemp("", "hs-vault-")
+	if err != nil {
+		cleanup()
+		return "", func() {}, err
+	}
+	fetched := cleanup
+	cleanup = func() {
+		_ = os.RemoveAll(tmp)
+		fetched()
+	}
+
+	if err := archive.Unpack(source, r.EncryptionKey, tmp); err != nil {
+		cleanup()
+		return "", func() {}, err
+	}
+	return tmp, cleanup, nil
+}
+
+func (r *runner) restorePath(ctx context.Context, client *vault.Client, source string) error {
+	mounts, err := ListMounts(ctx, client, r.Namespace)
+	if err != nil {
+		return err
+	}
+
+	mount, ok := mounts[r.Path]
+	if !ok {
+		return fmt.Errorf("engine with path '%v' not found", r.Path)
+	}
+
+	// source may be the whole backup instead of the engine directory
+	rp := source
+	if !strings.HasSuffix(path.Base(rp), fmt.Sprintf(".%v", mount.EngineType())) {
+		rp = path.Join(source, fmt.Sprintf("%v.%v", r.Path, mount.EngineType()))
+	}
+
+	mismatches, err := r.restoreEngine(ctx, client, r.Namespace, r.Path, mount, rp)
+	if err != nil {
+		return err
+	}
+	if mismatches > 0 {
+		return fmt.Errorf("restore verification failed with %d mismatches", mismatches)
+	}
+	return nil
+}
+
+// restoreEngines restores every selected engine of the client namespace found in source
+func (r *runner) restoreEngines(ctx context.Context, client *vault.Client, namespace, source string) error {
+	mounts, err := ListMounts(ctx, client, namespace)
+	if err != nil {
+		return err
+	}
+	mounts = selectMounts(mounts, r.Include, r.Exclude)
+
+	backups, err := ListBackupEngines(source)
+	if err != nil {
+		return err
+	}
+	for key := range backups {
+		if _, ok := mounts[key]; !ok {
+			r.logger.Warn("Engine not found, skip restore", zap.String("path", key))
+		}
+	}
+
+	var (
+		mu         sync.Mutex
+		mismatches int
+		errs       []error
+	)
+	// engines of the same step are restored concurrently, steps run in order
+	for _, keys := range restoreSteps(mounts) {
+		err := r.parallel(keys, func(key string) error {
+			mount := mounts[key]
+			rp := path.Join(source, fmt.Sprintf("%v.%v", key, mount.EngineType()))
+			if _, err := os.Stat(rp); os.IsNotExist(err) {
+				r.logger.Info("No backup found, skip restore", zap.String("path", key))
+				return nil
+			}
+
+			n, err := r.restoreEngine(ctx, client, namespace, key, mount, rp)
+			mu.Lock()
+			mismatches += n
+			mu.Unlock()
+			return err
+		})
+		if err := r.keepGoing(&errs, err); err != nil {
+			return err
+		}
+	}
+
+	if mismatches > 0 {
+		errs = append(errs, fmt.Errorf("restore verification failed with %d mismatches", mismatches))
+	}
+	return errors.Join(errs...)
+}
+
+// restoreEngine runs the restore and reads back what was written when verification is enabled,
+// it returns the number of mismatches with the backup
+func (r *runner) restoreEngine(ctx context.Context, client *vault.Client, namespace, key string, mount Mount, source string) (n int, err error) {
+	result := r.start(namespace, key, mount)
+	var se backends.Engine
+	defer func() { r.done(result, se, n, err) }()
+	defer func() { err = engineError("restore", namespace, key, mount, se, err) }()
+
+	se, err = NewEngine(client, key, mount, r.options("", source))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := se.Restore(ctx); err != nil {
+		return 0, err
+	}
+
+	if !r.Verify {
+		return 0, nil
+	}
+	rv, ok := se.(backends.RestoreVerifier)
+	if reg, _ := Lookup(key, mount); !ok || !reg.Capabilities.Verify {
+		r.logger.Warn("Restore verification is not supported, skip", zap.String("path", key), zap.String("type", mount.Type))
+		return 0, nil
+	}
+
+	mismatches, err := rv.VerifyRestore(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range mismatches {
+		r.logger.Warn("Restore mismatch", zap.String("path", key), zap.String("mismatch", m.String()))
+	}
+	return len(mismatches), nil
+}
+
+// options returns the engine options of a backup into dest or a restore from source
+func (r *runner) options(dest, source string) *backends.Options {
+	return &backends.Options{
+		Base64Encode:            r.Base64Encode,
+		BackupPath:              dest,
+		RestorePath:             source,
+		RawAccessible:           r.raw,
+		BackupSecretIDs:         r.BackupSecretIDs,
+		AuditRewrites:           r.AuditRewrites,
+		PathRemap:               r.PathRemap,
+		TransitRenumberVersions: r.TransitRenumberVersions,
+		VerifyRestore:           r.Verify,
+		ContinueOnError:         r.ContinueOnError,
+		Logger:                  r.logger,
+	}
+}
+
+// namespaced returns a copy of the client working in the namespace, the client itself when it is empty
+func namespaced(client *vault.Client, namespace string) (*vault.Client, error) {
+	if namespace == "" {
+		return client, nil
+	}
+	return NamespaceClient(client, namespace)
+}
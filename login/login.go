@@ -0,0 +1,286 @@
+package login
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/vault-client-go"
+	"github.com/hashicorp/vault-client-go/schema"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Method string
+
+const (
+	// Token uses VAULT_TOKEN, the token is renewed when it can be but never revoked
+	Token Method = "token"
+	// TokenFile reads the token from a file, eg: a Vault agent sink, the file is read again on renewal
+	TokenFile  Method = "token-file"
+	AppRole    Method = "approle"
+	Kubernetes Method = "kubernetes"
+	Userpass   Method = "userpass"
+
+	// DefaultJWTFile is where Kubernetes mounts the service account token in pods
+	DefaultJWTFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	// minRenewInterval keeps short-lived tokens from renewing in a tight loop
+	minRenewInterval = 5 * time.Second
+)
+
+var Supported = []Method{Token, TokenFile, AppRole, Kubernetes, Userpass}
+
+// Config selects the auth method of the tool and its credentials,
+// secrets can be given inline or read from files
+type Config struct {
+	Method Method
+	// Mount is the auth method path, defaults to the method name
+	Mount string
+
+	RoleID       string
+	RoleIDFile   string
+	SecretID     string
+	SecretIDFile string
+
+	Role    string
+	JWTFile string
+
+	Username     string
+	Password     string
+	PasswordFile string
+
+	TokenFile string
+}
+
+// Session holds the token of the tool, it renews the token in the background
+// until it is closed, tokens from a login are revoked on close.
+// A new token after a login again is sent by every request of the logged in client
+// and of its clones, eg: clients of namespaces.
+type Session struct {
+	client *vault.Client
+	// token is the current token, clones of client still hold the token they were made with
+	token atomic.Value
+	// auth renews and revokes the token, it is not affected by namespaces set on client
+	auth      *vault.Client
+	config    Config
+	logger    *zap.Logger
+	renewable bool
+	ttl       time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Login authenticates the client with the configured method and starts renewing its token
+func Login(ctx context.Context, client *vault.Client, config Config, logger *zap.Logger) (*Session, error) {
+	if config.Method == "" {
+		config.Method = Token
+	}
+	if config.Mount == "" {
+		config.Mount = string(config.Method)
+	}
+
+	s := &Session{client: client, auth: client.Clone(), config: config, logger: logger.With(zap.String("auth", string(config.Method)))}
+	// request callbacks are copied to clones
+	if err := client.SetRequestCallbacks(s.sendToken); err != nil {
+		return nil, err
+	}
+	if err := s.login(ctx); err != nil {
+		return nil, err
+	}
+
+	renewCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	if s.config.Method == TokenFile || s.renewable {
+		s.wg.Add(1)
+		go s.renew(renewCtx)
+	}
+	return s, nil
+}
+
+func (s *Session) login(ctx context.Context) error {
+	switch s.config.Method {
+	case Token:
+		if err := s.setToken(os.Getenv("VAULT_TOKEN")); err != nil {
+			return err
+		}
+		s.lookup(ctx)
+		return nil
+	case TokenFile:
+		token, err := readValue("", s.config.TokenFile)
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			return fmt.Errorf("token file '%v' is empty", s.config.TokenFile)
+		}
+		return s.setToken(token)
+	}
+
+	resp, err := s.authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("%v login: %w", s.config.Method, err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("%v login: no token returned", s.config.Method)
+	}
+
+	s.renewable = resp.Auth.Renewable
+	s.ttl = time.Duration(resp.Auth.LeaseDuration) * time.Second
+	s.logger.Debug("logged in", zap.Duration("ttl", s.ttl), zap.Bool("renewable", s.renewable))
+	return s.setToken(resp.Auth.ClientToken)
+}
+
+// lookup reads whether a given token can be renewed, tokens that can not be
+// looked up are used as they are
+func (s *Session) lookup(ctx context.Context) {
+	resp, err := s.auth.Auth.TokenLookUpSelf(ctx)
+	if err != nil {
+		s.logger.Debug("unable to look up token", zap.Error(err))
+		return
+	}
+	renewable, _ := resp.Data["renewable"].(bool)
+	ttl, _ := strconv.Atoi(fmt.Sprint(resp.Data["ttl"]))
+	s.renewable = renewable && ttl > 0
+	s.ttl = time.Duration(ttl) * time.Second
+}
+
+func (s *Session) setToken(token string) error {
+	if err := s.auth.SetToken(token); err != nil {
+		return err
+	}
+	if err := s.client.SetToken(token); err != nil {
+		return err
+	}
+	s.token.Store(token)
+	return nil
+}
+
+// sendToken replaces the token of a request with the current one
+func (s *Session) sendToken(r *http.Request) {
+	if token, _ := s.token.Load().(string); token != "" {
+		r.Header.Set("X-Vault-Token", token)
+	}
+}
+
+func (s *Session) authenticate(ctx context.Context) (*vault.Response[map[string]interface{}], error) {
+	mount := vault.WithMountPath(s.config.Mount)
+
+	switch s.config.Method {
+	case AppRole:
+		roleID, err := readValue(s.config.RoleID, s.config.RoleIDFile)
+		if err != nil {
+			return nil, err
+		}
+		secretID, err := readValue(s.config.SecretID, s.config.SecretIDFile)
+		if err != nil {
+			return nil, err
+		}
+		return s.auth.Auth.AppRoleLogin(ctx, schema.AppRoleLoginRequest{RoleId: roleID, SecretId: secretID}, mount)
+	case Kubernetes:
+		jwtFile := s.config.JWTFile
+		if jwtFile == "" {
+			jwtFile = DefaultJWTFile
+		}
+		jwt, err := readValue("", jwtFile)
+		if err != nil {
+			return nil, err
+		}
+		return s.auth.Auth.KubernetesLogin(ctx, schema.KubernetesLoginRequest{Role: s.config.Role, Jwt: jwt}, mount)
+	case Userpass:
+		password, err := readValue(s.config.Password, s.config.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		return s.auth.Auth.UserpassLogin(ctx, s.config.Username, schema.UserpassLoginRequest{Password: password}, mount)
+	}
+	return nil, fmt.Errorf("unsupported auth method '%v'", s.config.Method)
+}
+
+// renew renews the token at half of its ttl, logging in again when the token
+// can not be renewed anymore, token files are read again instead
+func (s *Session) renew(ctx context.Context) {
+	defer s.wg.Done()
+	l := s.logger.With(zap.String("method", "renew"))
+
+	for {
+		interval := s.ttl / 2
+		if s.config.Method == TokenFile {
+			interval = time.Minute
+		} else if interval < minRenewInterval {
+			interval = minRenewInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		if s.config.Method == TokenFile {
+			if err := s.login(ctx); err != nil {
+				l.Error("unable to read token file", zap.Error(err))
+			}
+			continue
+		}
+
+		resp, err := s.auth.Auth.TokenRenewSelf(ctx, schema.TokenRenewSelfRequest{})
+		if err == nil && resp.Auth != nil && resp.Auth.LeaseDuration > 0 {
+			ttl := time.Duration(resp.Auth.LeaseDuration) * time.Second
+			l.Debug("token renewed", zap.Duration("ttl", ttl))
+			// the ttl stops growing at the max ttl, log in again before the token expires
+			if ttl >= s.ttl/2 || s.config.Method == Token {
+				s.ttl = ttl
+				continue
+			}
+		} else if err != nil {
+			l.Warn("unable to renew token", zap.Error(err))
+		}
+
+		// a given token can not log in again, it is used until it expires
+		if s.config.Method == Token {
+			if err == nil {
+				return
+			}
+			continue
+		}
+
+		if err := s.login(ctx); err != nil {
+			l.Error("unable to log in again", zap.Error(err))
+			continue
+		}
+		l.Info("logged in again")
+	}
+}
+
+// Close stops renewing and revokes tokens the session logged in with
+func (s *Session) Close(ctx context.Context) error {
+	s.cancel()
+	s.wg.Wait()
+
+	if s.config.Method == Token || s.config.Method == TokenFile {
+		return nil
+	}
+	if _, err := s.auth.Auth.TokenRevokeSelf(ctx); err != nil {
+		return fmt.Errorf("unable to revoke token: %w", err)
+	}
+	s.logger.Debug("token revoked")
+	return nil
+}
+
+// readValue returns the value, or the trimmed content of the file when the value is empty
+func readValue(value, file string) (string, error) {
+	if value != "" || file == "" {
+		return value, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
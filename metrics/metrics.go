@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"context"
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const namespace = "hs_vault"
+
+var (
+	engineLabels = []string{"job", "operation", "namespace", "path", "type"}
+	runLabels    = []string{"job", "operation"}
+)
+
+// Metrics are the Prometheus collectors of backup and restore runs
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	EngineDuration *prometheus.GaugeVec
+	EngineKeys     *prometheus.GaugeVec
+	EngineBytes    *prometheus.GaugeVec
+	EngineSuccess  *prometheus.GaugeVec
+
+	EngineFailedKeys *prometheus.GaugeVec
+
+	APIErrors  *prometheus.CounterVec
+	APIRetries *prometheus.CounterVec
+
+	RunDuration *prometheus.GaugeVec
+	RunSuccess  *prometheus.GaugeVec
+	LastRun     *prometheus.GaugeVec
+	LastSuccess *prometheus.GaugeVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		EngineDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "engine_duration_seconds",
+			Help:      "Duration of the last backup or restore of an engine.",
+		}, engineLabels),
+		EngineKeys: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "engine_keys",
+			Help:      "Keys backed up, or written to Vault on restore, by the last run of an engine.",
+		}, engineLabels),
+		EngineBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "engine_bytes_written",
+			Help:      "Bytes of backup files written by the last backup of an engine.",
+		}, engineLabels),
+		EngineSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "engine_success",
+			Help:      "Whether the last run of an engine succeeded.",
+		}, engineLabels),
+		EngineFailedKeys: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "engine_failed_keys",
+			Help:      "Keys skipped by the last run of an engine with --continue-on-error.",
+		}, engineLabels),
+		APIErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_errors_total",
+			Help:      "Vault API responses with an error status, code is connection when Vault was not reached.",
+		}, []string{"job", "code"}),
+		APIRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_retries_total",
+			Help:      "Vault API requests retried.",
+		}, []string{"job"}),
+		RunDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "run_duration_seconds",
+			Help:      "Duration of the last run.",
+		}, runLabels),
+		RunSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "run_success",
+			Help:      "Whether the last run succeeded.",
+		}, runLabels),
+		LastRun: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_run_timestamp_seconds",
+			Help:      "Time the last run finished.",
+		}, runLabels),
+		LastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Time the last successful run finished.",
+		}, runLabels),
+	}
+
+	m.Registry.MustRegister(m.EngineDuration, m.EngineKeys, m.EngineBytes, m.EngineSuccess, m.EngineFailedKeys, m.APIErrors, m.APIRetries,
+		m.RunDuration, m.RunSuccess, m.LastRun, m.LastSuccess)
+	return m
+}
+
+// CheckRetry wraps the retry policy of the Vault client to count API errors of a job
+func (m *Metrics) CheckRetry(job string, policy retryablehttp.CheckRetry) retryablehttp.CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if err != nil {
+			m.APIErrors.WithLabelValues(job, "connection").Inc()
+		} else if resp != nil && resp.StatusCode >= 400 {
+			m.APIErrors.WithLabelValues(job, strconv.Itoa(resp.StatusCode)).Inc()
+		}
+		return policy(ctx, resp, err)
+	}
+}
+
+// Backoff wraps the backoff of the Vault client to count retries of a job, it is only called before a retry
+func (m *Metrics) Backoff(job string, backoff retryablehttp.Backoff) retryablehttp.Backoff {
+	return func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		m.APIRetries.WithLabelValues(job).Inc()
+		return backoff(min, max, attemptNum, resp)
+	}
+}
+
+// WriteTextfile writes the metrics for the node exporter textfile collector. The last success of
+// previous runs is read back from the file, so a failed run does not reset it
+func (m *Metrics) WriteTextfile(f string) error {
+	m.loadLastSuccess(f)
+	return prometheus.WriteToTextfile(f, m.Registry)
+}
+
+func (m *Metrics) loadLastSuccess(f string) {
+	file, err := os.Open(f)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(file)
+	if err != nil {
+		return
+	}
+
+	family, ok := families[namespace+"_last_success_timestamp_seconds"]
+	if !ok {
+		return
+	}
+	for _, metric := range family.GetMetric() {
+		labels := prometheus.Labels{}
+		for _, l := range metric.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if len(labels) != len(runLabels) {
+			continue
+		}
+
+		// successes of runs of this process are newer
+		if m.lastSuccess(labels) != 0 {
+			continue
+		}
+		if g, err := m.LastSuccess.GetMetricWith(labels); err == nil {
+			g.Set(metric.GetGauge().GetValue())
+		}
+	}
+}
+
+func (m *Metrics) lastSuccess(labels prometheus.Labels) float64 {
+	families, err := m.Registry.Gather()
+	if err != nil {
+		return 0
+	}
+	for _, family := range families {
+		if family.GetName() != namespace+"_last_success_timestamp_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			match := true
+			for _, l := range metric.GetLabel() {
+				if labels[l.GetName()] != l.GetValue() {
+					match = false
+				}
+			}
+			if match {
+				return metric.GetGauge().GetValue()
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusSuccess = "success"
+	StatusPartial = "partial"
+	StatusFailed  = "failed"
+)
+
+// Failure is an engine error, it is partial when the engine went through and only some keys failed
+type Failure interface {
+	error
+	Partial() bool
+}
+
+// FailedKey is a key skipped by an engine
+type FailedKey struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// EngineResult is the outcome of the backup or restore of one engine
+type EngineResult struct {
+	Namespace  string      `json:"namespace,omitempty"`
+	Path       string      `json:"path"`
+	Type       string      `json:"type"`
+	Status     string      `json:"status"`
+	Error      string      `json:"error,omitempty"`
+	Start      time.Time   `json:"start"`
+	Duration   float64     `json:"duration_seconds"`
+	Keys       int         `json:"keys"`
+	Bytes      int64       `json:"bytes_written,omitempty"`
+	Mismatches int         `json:"mismatches,omitempty"`
+	FailedKeys []FailedKey `json:"failed_keys,omitempty"`
+}
+
+// Run collects engine results of a backup or restore run into metrics and a JSON report
+type Run struct {
+	Job       string         `json:"job,omitempty"`
+	Operation string         `json:"operation"`
+	Status    string         `json:"status"`
+	Error     string         `json:"error,omitempty"`
+	Start     time.Time      `json:"start"`
+	End       time.Time      `json:"end"`
+	Duration  float64        `json:"duration_seconds"`
+	Keys      int            `json:"keys"`
+	Bytes     int64          `json:"bytes_written,omitempty"`
+	Engines   []EngineResult `json:"engines"`
+
+	metrics *Metrics
+	mu      sync.Mutex
+}
+
+// StartRun starts recording a run, job is empty outside of jobs
+func (m *Metrics) StartRun(job, operation string) *Run {
+	return &Run{Job: job, Operation: operation, Start: time.Now(), Engines: []EngineResult{}, metrics: m}
+}
+
+// AddEngine records the result of an engine and returns it, the status is set from err
+func (r *Run) AddEngine(e EngineResult, err error) EngineResult {
+	e.Status = StatusSuccess
+	success := 1.0
+	if err != nil {
+		e.Status, e.Error = StatusFailed, err.Error()
+		success = 0
+
+		var f Failure
+		if errors.As(err, &f) && f.Partial() {
+			e.Status = StatusPartial
+		}
+	}
+
+	labels := []string{r.Job, r.Operation, e.Namespace, e.Path, e.Type}
+	r.metrics.EngineDuration.WithLabelValues(labels...).Set(e.Duration)
+	r.metrics.EngineKeys.WithLabelValues(labels...).Set(float64(e.Keys))
+	r.metrics.EngineBytes.WithLabelValues(labels...).Set(float64(e.Bytes))
+	r.metrics.EngineSuccess.WithLabelValues(labels...).Set(success)
+	r.metrics.EngineFailedKeys.WithLabelValues(labels...).Set(float64(len(e.FailedKeys)))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Engines = append(r.Engines, e)
+	r.Keys += e.Keys
+	r.Bytes += e.Bytes
+	return e
+}
+
+// Finish records the end of the run. When the run went on after errors, it is partial if err only
+// holds engine failures and some engine succeeded, at least partially. It failed otherwise
+func (r *Run) Finish(err error, wentOn bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.End = time.Now()
+	r.Duration = r.End.Sub(r.Start).Seconds()
+	r.Status = StatusSuccess
+	if err != nil {
+		r.Status, r.Error = StatusFailed, err.Error()
+		if wentOn && onlyFailures(err) && r.succeeded() {
+			r.Status = StatusPartial
+		}
+	}
+	sort.Slice(r.Engines, func(i, k int) bool {
+		if r.Engines[i].Namespace != r.Engines[k].Namespace {
+			return r.Engines[i].Namespace < r.Engines[k].Namespace
+		}
+		return r.Engines[i].Path < r.Engines[k].Path
+	})
+
+	m := r.metrics
+	m.RunDuration.WithLabelValues(r.Job, r.Operation).Set(r.Duration)
+	m.LastRun.WithLabelValues(r.Job, r.Operation).Set(float64(r.End.Unix()))
+	if err != nil {
+		m.RunSuccess.WithLabelValues(r.Job, r.Operation).Set(0)
+		return
+	}
+	m.RunSuccess.WithLabelValues(r.Job, r.Operation).Set(1)
+	m.LastSuccess.WithLabelValues(r.Job, r.Operation).Set(float64(r.End.Unix()))
+}
+
+// onlyFailures reports whether err and every error joined in it are engine failures
+func onlyFailures(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if !onlyFailures(e) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var f Failure
+	return errors.As(err, &f)
+}
+
+func (r *Run) succeeded() bool {
+	for _, e := range r.Engines {
+		if e.Status != StatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteReport writes the run as indented JSON
+func (r *Run) WriteReport(f string) error {
+	r.mu.Lock()
+	content, err := json.MarshalIndent(r, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f, content, 0644)
+}
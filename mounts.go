@@ -0,0 +1,192 @@
+package hsvault
+
+import (
+	"context"
+	"github.com/hashicorp/vault-client-go"
+	"github.com/mitchellh/mapstructure"
+	"github.com/zduymz/hs-vault/auths"
+	"github.com/zduymz/hs-vault/backends"
+	"github.com/zduymz/hs-vault/system"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Mount is a secrets engine, an auth method or a system component
+type Mount struct {
+	Accessor    string
+	Description string
+	Local       bool
+	Type        string
+	Uuid        string
+	Options     map[string]interface{}
+}
+
+// EngineType returns the engine implementation of the mount, kv mounts are kv or kv2 by version
+func (m *Mount) EngineType() backends.EngineType {
+	if m.Type == "kv" {
+		if version, ok := m.Options["version"]; ok && version.(string) == "2" {
+			return backends.SecretV2Engine
+		}
+		return backends.SecretV1Engine
+	}
+	return backends.EngineType(m.Type)
+}
+
+// ListSecretsEngines returns secrets engines having a registered implementation keyed by their path
+func ListSecretsEngines(ctx context.Context, v *vault.Client) (map[string]Mount, error) {
+	engines, err := v.System.MountsListSecretsEngines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var secretEngines = make(map[string]Mount)
+	for key, value := range engines.Data {
+		key = strings.TrimSuffix(key, "/")
+		output := Mount{}
+		if err := mapstructure.Decode(value, &output); err != nil {
+			return nil, err
+		}
+
+		if !backends.Supported(output.EngineType()) {
+			continue
+		}
+		secretEngines[key] = output
+	}
+	return secretEngines, nil
+}
+
+// listAuthMethods returns auth methods having a registered implementation keyed by their API path, eg: auth/approle
+func listAuthMethods(ctx context.Context, v *vault.Client) (map[string]Mount, error) {
+	methods, err := v.System.AuthListEnabledMethods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var authMethods = make(map[string]Mount)
+	for key, value := range methods.Data {
+		key = path.Join("auth", strings.TrimSuffix(key, "/"))
+		output := Mount{}
+		if err := mapstructure.Decode(value, &output); err != nil {
+			return nil, err
+		}
+
+		if !auths.Supported(auths.AuthType(output.Type)) {
+			continue
+		}
+		authMethods[key] = output
+	}
+	return authMethods, nil
+}
+
+// ListMounts returns secrets engines, auth methods and system components of the client namespace,
+// auth methods are keyed by auth/<path> and system components by sys/<path>
+func ListMounts(ctx context.Context, v *vault.Client, namespace string) (map[string]Mount, error) {
+	mounts, err := ListSecretsEngines(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	authMethods, err := listAuthMethods(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	for key, method := range authMethods {
+		mounts[key] = method
+	}
+
+	for key, ct := range system.Components(namespace) {
+		mounts[key] = Mount{Type: string(ct)}
+	}
+	return mounts, nil
+}
+
+// NewEngine returns the engine backing up and restoring the mount at key
+func NewEngine(v *vault.Client, key string, mount Mount, options *backends.Options) (backends.Engine, error) {
+	se := &backends.SecretEngine{
+		Path: key,
+		Type: mount.Type,
+		UUID: mount.Uuid,
+	}
+
+	if strings.HasPrefix(key, "auth/") {
+		return auths.NewAuthMethod(v, se, options, auths.AuthType(mount.Type))
+	}
+	if strings.HasPrefix(key, "sys/") {
+		return system.NewComponent(v, se, options, system.ComponentType(mount.Type))
+	}
+	return backends.NewSecretEngine(v, se, options, mount.EngineType())
+}
+
+// Lookup returns the registered implementation of the mount at key
+func Lookup(key string, mount Mount) (backends.Registration, bool) {
+	if strings.HasPrefix(key, "auth/") {
+		return auths.Lookup(auths.AuthType(mount.Type))
+	}
+	if strings.HasPrefix(key, "sys/") {
+		return system.Lookup(system.ComponentType(mount.Type))
+	}
+	return backends.Lookup(mount.EngineType())
+}
+
+// RawAccessible reports whether the token can use sys/raw, engines back up more configuration with it
+func RawAccessible(ctx context.Context, v *vault.Client) bool {
+	_, err := v.System.RawList(ctx, "/")
+	return err == nil
+}
+
+// selectMounts keeps mounts matching include globs, all by default, and not matching exclude globs
+func selectMounts(mounts map[string]Mount, include, exclude []string) map[string]Mount {
+	if len(include) == 0 && len(exclude) == 0 {
+		return mounts
+	}
+
+	selected := map[string]Mount{}
+	for key, mount := range mounts {
+		if (len(include) == 0 || matchAny(include, key)) && !matchAny(exclude, key) {
+			selected[key] = mount
+		}
+	}
+	return selected
+}
+
+func matchAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreSteps groups mount paths by restore step, engines of a step can be restored concurrently.
+// Policies must exist before anything referencing them is restored, components referring to mounts come last
+func restoreSteps(mounts map[string]Mount) [][]string {
+	var keys []string
+	for key := range mounts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var steps [][]string
+	for _, key := range keys {
+		rank := restoreRank(mounts, key)
+		for len(steps) <= rank {
+			steps = append(steps, nil)
+		}
+		steps[rank] = append(steps[rank], key)
+	}
+	return steps
+}
+
+// restoreRank returns the restore step of a mount, system components first, engines, then
+// components referring to mounts
+func restoreRank(mounts map[string]Mount, key string) int {
+	if !strings.HasPrefix(key, "sys/") {
+		return 1
+	}
+	if system.RestoreAfterEngines(system.ComponentType(mounts[key].Type)) {
+		return 2
+	}
+	return 0
+}
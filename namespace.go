@@ -0,0 +1,228 @@
+package hsvault
+
+import (
+	"context"
+	"errors"
+	"github.com/hashicorp/vault-client-go"
+	"github.com/hashicorp/vault-client-go/schema"
+	"go.uber.org/zap"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// listNamespaces returns direct child namespaces of the client namespace
+func listNamespaces(ctx context.Context, v *vault.Client) ([]string, error) {
+	resp, err := v.List(ctx, "sys/namespaces")
+	if err != nil {
+		if vault.IsErrorStatus(err, 404) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var namespaces []string
+	keys, _ := resp.Data["keys"].([]interface{})
+	for _, k := range keys {
+		namespaces = append(namespaces, strings.TrimSuffix(k.(string), "/"))
+	}
+	return namespaces, nil
+}
+
+// NamespaceClient returns a copy of the client working in the namespace
+func NamespaceClient(v *vault.Client, namespace string) (*vault.Client, error) {
+	client := v.Clone()
+	if err := client.SetNamespace(namespace); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// NamespaceDir returns where a namespace is stored in a backup taken recursively
+func NamespaceDir(dir, namespace string) string {
+	for _, ns := range strings.Split(strings.Trim(namespace, "/"), "/") {
+		if ns != "" {
+			dir = path.Join(dir, "namespaces", ns)
+		}
+	}
+	return dir
+}
+
+// ListBackupEngines returns engines found in a backup directory keyed by path with their type,
+// engine directories are named <engine path>.<engine type> where the type is a registered one
+func ListBackupEngines(dir string) (map[string]string, error) {
+	engines := map[string]string{}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() || p == dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "namespaces" {
+			return filepath.SkipDir
+		}
+
+		// mount paths may have dots, eg: v1.0/secret.kv, only registered types end an engine path
+		ext := path.Ext(d.Name())
+		key := strings.TrimSuffix(filepath.ToSlash(rel), ext)
+		engineType := strings.TrimPrefix(ext, ".")
+		if _, ok := Lookup(key, Mount{Type: engineType}); !ok {
+			return nil
+		}
+		engines[key] = engineType
+		return filepath.SkipDir
+	})
+
+	return engines, err
+}
+
+// backupNamespace backs up the client namespace into dest and its children into dest/namespaces/<name>
+func (r *runner) backupNamespace(ctx context.Context, client *vault.Client, namespace, dest string) error {
+	var errs []error
+	if err := r.keepGoing(&errs, r.backupEngines(ctx, client, namespace, dest)); err != nil {
+		return err
+	}
+
+	children, err := listNamespaces(ctx, client)
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	for _, child := range children {
+		ns := path.Join(namespace, child)
+		r.logger.Info("Backup namespace", zap.String("namespace", ns))
+		nc, err := NamespaceClient(client, ns)
+		if err != nil {
+			if err := r.keepGoing(&errs, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := r.keepGoing(&errs, r.backupNamespace(ctx, nc, ns, path.Join(dest, "namespaces", child))); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// restoreNamespace restores the client namespace from source and its children from source/namespaces/<name>,
+// missing child namespaces are created with the engines found in the backup
+func (r *runner) restoreNamespace(ctx context.Context, client *vault.Client, namespace, source string) error {
+	var errs []error
+	if err := r.keepGoing(&errs, r.restoreEngines(ctx, client, namespace, source)); err != nil {
+		return err
+	}
+
+	dirs, err := os.ReadDir(path.Join(source, "namespaces"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errors.Join(errs...)
+		}
+		return errors.Join(append(errs, err)...)
+	}
+
+	existing, err := listNamespaces(ctx, client)
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+
+		child := d.Name()
+		if err := r.keepGoing(&errs, r.restoreChild(ctx, client, namespace, child, source, !contains(existing, child))); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// restoreChild restores the child namespace from source/namespaces/<child>, it is created first when missing
+func (r *runner) restoreChild(ctx context.Context, client *vault.Client, namespace, child, source string, missing bool) error {
+	ns := path.Join(namespace, child)
+	childSource := path.Join(source, "namespaces", child)
+
+	if missing {
+		r.logger.Info("Create namespace", zap.String("namespace", ns))
+		if _, err := client.Write(ctx, path.Join("sys/namespaces", child), nil); err != nil {
+			return err
+		}
+	}
+
+	nc, err := NamespaceClient(client, ns)
+	if err != nil {
+		return err
+	}
+
+	if missing {
+		if err := r.enableEngines(ctx, nc, childSource); err != nil {
+			return err
+		}
+	}
+
+	r.logger.Info("Restore namespace", zap.String("namespace", ns))
+	return r.restoreNamespace(ctx, nc, ns, childSource)
+}
+
+// enableEngines mounts secrets engines and auth methods found in the backup with default settings
+func (r *runner) enableEngines(ctx context.Context, v *vault.Client, source string) error {
+	engines, err := ListBackupEngines(source)
+	if err != nil {
+		return err
+	}
+
+	for key, engineType := range engines {
+		switch {
+		case strings.HasPrefix(key, "sys/"), key == "identity", key == "auth/token":
+			continue
+		case strings.HasPrefix(key, "auth/"):
+			r.logger.Info("Enable auth method", zap.String("path", key))
+			if _, err := v.System.AuthEnableMethod(ctx, strings.TrimPrefix(key, "auth/"), schema.AuthEnableMethodRequest{
+				Type: engineType,
+			}); err != nil {
+				return err
+			}
+		default:
+			request := schema.MountsEnableSecretsEngineRequest{Type: engineType}
+			switch engineType {
+			case "kv":
+				request.Options = map[string]interface{}{"version": "1"}
+			case "kv2":
+				request.Type = "kv"
+				request.Options = map[string]interface{}{"version": "2"}
+			}
+
+			r.logger.Info("Enable secrets engine", zap.String("path", key))
+			if _, err := v.System.MountsEnableSecretsEngine(ctx, key, request); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,381 @@
+package hsvault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/vault-client-go"
+	"github.com/zduymz/hs-vault/backends"
+	"github.com/zduymz/hs-vault/metrics"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeVault serves the namespace, mount and kv v1 endpoints a recursive run uses, every namespace
+// has its own mounts and secrets selected by the X-Vault-Namespace header
+type fakeVault struct {
+	mu sync.Mutex
+	// children are direct child namespaces keyed by their parent
+	children map[string][]string
+	mounts   map[string]map[string]interface{}
+	// secrets are keyed by <namespace>/<mount>/<key>, denied ones can be listed but not read
+	secrets  map[string]map[string]interface{}
+	denied   map[string]bool
+	requests []string
+}
+
+func newFakeVault() *fakeVault {
+	return &fakeVault{
+		children: map[string][]string{},
+		mounts:   map[string]map[string]interface{}{},
+		secrets:  map[string]map[string]interface{}{},
+		denied:   map[string]bool{},
+	}
+}
+
+// addNamespace creates a namespace without engines
+func (f *fakeVault) addNamespace(namespace string) {
+	if parent := path.Dir(namespace); parent != "." {
+		f.children[parent] = append(f.children[parent], path.Base(namespace))
+	}
+	f.mounts[namespace] = map[string]interface{}{}
+}
+
+func (f *fakeVault) mount(namespace, name, engineType string, options map[string]interface{}) {
+	f.mounts[namespace][name+"/"] = map[string]interface{}{
+		"type":    engineType,
+		"options": options,
+		"uuid":    fmt.Sprintf("uuid-%v-%v", namespace, name),
+	}
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ns := strings.Trim(r.Header.Get("X-Vault-Namespace"), "/")
+	p := strings.TrimPrefix(r.URL.Path, "/v1/")
+	list := r.URL.Query().Get("list") == "true"
+	method := r.Method
+	if list {
+		method = "LIST"
+	}
+	f.requests = append(f.requests, fmt.Sprintf("%v %v %v", ns, method, p))
+
+	mounts, ok := f.mounts[ns]
+	if !ok {
+		reply(w, http.StatusNotFound, nil)
+		return
+	}
+
+	switch {
+	case p == "sys/mounts":
+		reply(w, http.StatusOK, mounts)
+	case p == "sys/auth":
+		reply(w, http.StatusOK, map[string]interface{}{})
+	case strings.HasPrefix(p, "sys/raw"):
+		reply(w, http.StatusForbidden, nil)
+	case p == "sys/namespaces" && list:
+		if len(f.children[ns]) == 0 {
+			reply(w, http.StatusNotFound, nil)
+			return
+		}
+		var keys []interface{}
+		for _, child := range f.children[ns] {
+			keys = append(keys, child+"/")
+		}
+		reply(w, http.StatusOK, map[string]interface{}{"keys": keys})
+	case strings.HasPrefix(p, "sys/namespaces/") && r.Method != http.MethodGet:
+		f.addNamespace(path.Join(ns, strings.TrimPrefix(p, "sys/namespaces/")))
+		reply(w, http.StatusNoContent, nil)
+	case strings.HasPrefix(p, "sys/mounts/") && r.Method != http.MethodGet:
+		var request struct {
+			Type    string                 `json:"type"`
+			Options map[string]interface{} `json:"options"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			reply(w, http.StatusBadRequest, nil)
+			return
+		}
+		f.mount(ns, strings.TrimPrefix(p, "sys/mounts/"), request.Type, request.Options)
+		reply(w, http.StatusNoContent, nil)
+	case mounts[strings.SplitN(p, "/", 2)[0]+"/"] != nil:
+		f.serveSecret(w, r, path.Join(ns, p), list)
+	default:
+		reply(w, http.StatusNotFound, nil)
+	}
+}
+
+func (f *fakeVault) serveSecret(w http.ResponseWriter, r *http.Request, key string, list bool) {
+	switch {
+	case list:
+		var keys []interface{}
+		for k := range f.secrets {
+			if strings.HasPrefix(k, key+"/") {
+				keys = append(keys, strings.TrimPrefix(k, key+"/"))
+			}
+		}
+		if len(keys) == 0 {
+			reply(w, http.StatusNotFound, nil)
+			return
+		}
+		reply(w, http.StatusOK, map[string]interface{}{"keys": keys})
+	case f.denied[key]:
+		reply(w, http.StatusForbidden, nil)
+	case r.Method == http.MethodGet:
+		data, ok := f.secrets[key]
+		if !ok {
+			reply(w, http.StatusNotFound, nil)
+			return
+		}
+		reply(w, http.StatusOK, data)
+	default:
+		data := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			reply(w, http.StatusBadRequest, nil)
+			return
+		}
+		f.secrets[key] = data
+		reply(w, http.StatusNoContent, nil)
+	}
+}
+
+func reply(w http.ResponseWriter, status int, data interface{}) {
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if status >= 400 {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{http.StatusText(status)}})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+// received counts requests like "parent/team LIST sys/namespaces" sent to the fake
+func (f *fakeVault) received(request string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, r := range f.requests {
+		if r == request {
+			n++
+		}
+	}
+	return n
+}
+
+func newFakeClient(t *testing.T, f *fakeVault) *vault.Client {
+	t.Helper()
+
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	client, err := vault.New(vault.WithAddress(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetToken("root"); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// backupParent backs up parent and parent/team recursively, both have a kv engine at secret
+func backupParent(t *testing.T) string {
+	t.Helper()
+
+	f := newFakeVault()
+	for _, ns := range []string{"parent", "parent/team"} {
+		f.addNamespace(ns)
+		f.mount(ns, "secret", "kv", map[string]interface{}{"version": "1"})
+	}
+	f.secrets["parent/secret/a"] = map[string]interface{}{"value": "1"}
+	f.secrets["parent/team/secret/b"] = map[string]interface{}{"value": "2"}
+
+	dir := t.TempDir()
+	config := Config{Namespace: "parent", Recursive: true, Include: []string{"secret"}, Dir: dir}
+	if _, err := Backup(context.Background(), newFakeClient(t, f), config); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, request := range []string{
+		"parent GET sys/mounts",
+		"parent LIST sys/namespaces",
+		"parent/team GET sys/mounts",
+		"parent/team LIST sys/namespaces",
+		"parent/team GET secret/b",
+	} {
+		if f.received(request) == 0 {
+			t.Errorf("request '%v' not sent", request)
+		}
+	}
+	if f.received("parent GET secret/b") != 0 {
+		t.Errorf("secret of parent/team read in parent")
+	}
+	return dir
+}
+
+func TestBackupNamespaceLayout(t *testing.T) {
+	dir := backupParent(t)
+
+	for engineDir, want := range map[string]string{
+		path.Join(dir, "secret.kv"):                 "/a",
+		path.Join(dir, "namespaces/team/secret.kv"): "/b",
+	} {
+		entries, err := backends.ReadChunks(engineDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if strings.Join(keys, ",") != want {
+			t.Errorf("keys of '%v' = %v, want %v", engineDir, keys, want)
+		}
+	}
+
+	engines, err := ListBackupEngines(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(engines) != 1 || engines["secret"] != "kv" {
+		t.Errorf("engines of '%v' = %v, child namespaces must not be listed", dir, engines)
+	}
+}
+
+func TestRestoreCreatesMissingNamespace(t *testing.T) {
+	dir := backupParent(t)
+
+	f := newFakeVault()
+	f.addNamespace("parent")
+	f.mount("parent", "secret", "kv", map[string]interface{}{"version": "1"})
+	client := newFakeClient(t, f)
+	config := Config{Namespace: "parent", Recursive: true, Include: []string{"secret"}, Dir: dir}
+
+	if _, err := Restore(context.Background(), client, config); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := f.received("parent POST sys/namespaces/team"); n != 1 {
+		t.Errorf("namespace parent/team created %d times, want 1", n)
+	}
+	if n := f.received("parent/team POST sys/mounts/secret"); n != 1 {
+		t.Errorf("secret of parent/team enabled %d times, want 1", n)
+	}
+
+	mount, _ := f.mounts["parent/team"]["secret/"].(map[string]interface{})
+	options, _ := mount["options"].(map[string]interface{})
+	if mount["type"] != "kv" || options["version"] != "1" {
+		t.Errorf("secret of parent/team mounted as %v, want kv version 1", mount)
+	}
+
+	for key, want := range map[string]string{"parent/secret/a": "1", "parent/team/secret/b": "2"} {
+		if got := f.secrets[key]["value"]; got != want {
+			t.Errorf("value of '%v' = %v, want %v", key, got, want)
+		}
+	}
+
+	// an existing namespace is restored as it is
+	if _, err := Restore(context.Background(), client, config); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.received("parent POST sys/namespaces/team"); n != 1 {
+		t.Errorf("namespace parent/team created %d times, want 1", n)
+	}
+	if n := f.received("parent/team POST sys/mounts/secret"); n != 1 {
+		t.Errorf("secret of parent/team enabled %d times, want 1", n)
+	}
+}
+
+func TestListBackupEnginesDottedPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, d := range []string{"v1.0/secret.kv/sub", "auth/app.role.approle", "kv.kv2", "notes.txt.d"} {
+		if err := os.MkdirAll(path.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engines, err := ListBackupEngines(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"v1.0/secret": "kv", "auth/app.role": "approle", "kv": "kv2"}
+	if fmt.Sprint(engines) != fmt.Sprint(want) {
+		t.Errorf("engines = %v, want %v", engines, want)
+	}
+}
+
+func TestBackupCallbacksAndStatus(t *testing.T) {
+	f := newFakeVault()
+	f.addNamespace("parent")
+	for _, name := range []string{"bad", "good"} {
+		f.mount("parent", name, "kv", map[string]interface{}{"version": "1"})
+	}
+	f.secrets["parent/good/a"] = map[string]interface{}{"value": "1"}
+	f.secrets["parent/good/b"] = map[string]interface{}{"value": "2"}
+	f.secrets["parent/bad/ok"] = map[string]interface{}{"value": "3"}
+	f.secrets["parent/bad/denied"] = map[string]interface{}{"value": "4"}
+	f.denied["parent/bad/denied"] = true
+
+	var (
+		mu     sync.Mutex
+		events []string
+		keys   = map[string]int{}
+	)
+	core, logs := observer.New(zap.InfoLevel)
+	config := Config{
+		Namespace:       "parent",
+		Include:         []string{"bad", "good"},
+		Dir:             t.TempDir(),
+		ContinueOnError: true,
+		Logger:          zap.New(core),
+		OnEngineStart: func(e metrics.EngineResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, "start "+e.Path)
+		},
+		OnEngineDone: func(e metrics.EngineResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, fmt.Sprintf("done %v %v", e.Path, e.Status))
+			keys[e.Path] = e.Keys
+		},
+	}
+
+	run, err := Backup(context.Background(), newFakeClient(t, f), config)
+	if err == nil {
+		t.Fatal("backup with a denied key succeeded")
+	}
+
+	want := []string{"start bad", "done bad partial", "start good", "done good success"}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	if keys["bad"] != 1 || keys["good"] != 2 {
+		t.Errorf("keys = %v, want bad: 1, good: 2", keys)
+	}
+	if run.Status != metrics.StatusPartial {
+		t.Errorf("run status = %v, want %v", run.Status, metrics.StatusPartial)
+	}
+	if len(run.Engines) != 2 || len(run.Engines[0].FailedKeys) != 1 || run.Engines[0].FailedKeys[0].Key != "denied" {
+		t.Errorf("engines = %+v, want the denied key of bad failed", run.Engines)
+	}
+	if logs.FilterMessage("Backup written").Len() != 1 {
+		t.Errorf("injected logger did not receive the run logs")
+	}
+}
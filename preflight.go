@@ -0,0 +1,326 @@
+package hsvault
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/vault-client-go"
+	"github.com/hashicorp/vault-client-go/schema"
+	"github.com/zduymz/hs-vault/backends"
+	"go.uber.org/zap"
+	"path"
+	"strings"
+)
+
+// Requirement is a path a run uses with the capabilities it needs, Path is relative to Namespace
+type Requirement struct {
+	Namespace string `json:"namespace,omitempty"`
+	// Engine is the mount path, empty for paths used by the run itself
+	Engine       string   `json:"engine,omitempty"`
+	Type         string   `json:"type,omitempty"`
+	Path         string   `json:"path"`
+	Capabilities []string `json:"capabilities"`
+	// Optional requirements degrade the engine when they are missing, eg: sys/raw paths of engines
+	// backing up more configuration with it
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Requirements returns the paths a backup or restore of config uses in the namespace of the client and,
+// when recursive, in its children. sys/raw paths are only returned when raw is set or the engine requires them
+func Requirements(ctx context.Context, client *vault.Client, config Config, operation string, raw bool) ([]Requirement, error) {
+	client, err := namespaced(client, config.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return requirements(ctx, client, config, operation, raw, config.Namespace)
+}
+
+func requirements(ctx context.Context, client *vault.Client, config Config, operation string, raw bool, namespace string) ([]Requirement, error) {
+	reqs := runRequirements(config, operation, raw, namespace)
+
+	mounts, err := ListMounts(ctx, client, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if config.Path != "" {
+		mount, ok := mounts[config.Path]
+		if !ok {
+			return nil, fmt.Errorf("engine with path '%v' not found", config.Path)
+		}
+		mounts = map[string]Mount{config.Path: mount}
+	} else {
+		mounts = selectMounts(mounts, config.Include, config.Exclude)
+	}
+
+	var keys []string
+	for key := range mounts {
+		keys = append(keys, key)
+	}
+	for _, key := range sorted(keys) {
+		reqs = append(reqs, engineRequirements(config, operation, raw, namespace, key, mounts[key])...)
+	}
+
+	if !config.Recursive || config.Path != "" {
+		return reqs, nil
+	}
+
+	children, err := listNamespaces(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		ns := path.Join(namespace, child)
+		nc, err := NamespaceClient(client, ns)
+		if err != nil {
+			return nil, err
+		}
+		childReqs, err := requirements(ctx, nc, config, operation, raw, ns)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, childReqs...)
+	}
+	return reqs, nil
+}
+
+// runRequirements returns the paths used to list mounts and namespaces, restores of child namespaces
+// create the missing ones with their engines
+func runRequirements(config Config, operation string, raw bool, namespace string) []Requirement {
+	accesses := backends.Reads("sys/mounts", "sys/auth")
+	if raw {
+		accesses = append(accesses, backends.Access{Path: "sys/raw/", Capabilities: []string{"list", "sudo"}, Raw: true})
+	}
+	if config.Recursive && config.Path == "" {
+		accesses = append(accesses, backends.Access{Path: "sys/namespaces/*", Capabilities: []string{"list"}})
+		if operation == "restore" {
+			accesses = append(accesses, backends.Writes("sys/namespaces/*", "sys/mounts/*")...)
+			accesses = append(accesses, backends.Access{Path: "sys/auth/*", Capabilities: []string{"create", "update", "sudo"}})
+		}
+	}
+
+	var reqs []Requirement
+	for _, a := range accesses {
+		reqs = append(reqs, Requirement{
+			Namespace:    namespace,
+			Path:         a.Path,
+			Capabilities: a.Capabilities,
+			Optional:     a.Raw,
+		})
+	}
+	return reqs
+}
+
+// engineRequirements returns the paths the registered implementation of the mount declares, restore
+// verification reads back what is written
+func engineRequirements(config Config, operation string, raw bool, namespace, key string, mount Mount) []Requirement {
+	reg, ok := Lookup(key, mount)
+	if !ok {
+		return nil
+	}
+
+	accesses := reg.Capabilities.Paths.Backup
+	if operation == "restore" {
+		accesses = reg.Capabilities.Paths.Restore
+	}
+
+	var reqs []Requirement
+	for _, a := range accesses {
+		if a.Raw && !reg.Capabilities.RequiresRaw && (!raw || reg.Capabilities.NonRaw) {
+			continue
+		}
+
+		caps := a.Capabilities
+		if operation == "restore" && config.Verify && !contains(caps, "read") {
+			caps = append(append([]string{}, caps...), "read")
+		}
+
+		reqs = append(reqs, Requirement{
+			Namespace:    namespace,
+			Engine:       key,
+			Type:         string(mount.EngineType()),
+			Path:         accessPath(key, mount, a),
+			Capabilities: caps,
+			Optional:     a.Raw && !reg.Capabilities.RequiresRaw,
+		})
+	}
+	return reqs
+}
+
+// accessPath returns the API path of an access of the mount at key, raw paths are in the storage
+// of the mount: sys/raw/logical/<uuid> or sys/raw/auth/<uuid>
+func accessPath(key string, mount Mount, a backends.Access) string {
+	base := key
+	if a.Raw {
+		storage := "logical"
+		if strings.HasPrefix(key, "auth/") {
+			storage = "auth"
+		}
+		base = path.Join("sys/raw", storage, mount.Uuid)
+	}
+
+	if a.Path == "" {
+		return base
+	}
+	// path.Join would drop the trailing / of directories
+	return base + "/" + a.Path
+}
+
+// Check is a requirement with the capabilities the token lacks
+type Check struct {
+	Requirement
+	Missing []string `json:"missing,omitempty"`
+}
+
+// PreflightReport tells whether the token can run a backup or restore
+type PreflightReport struct {
+	Operation string `json:"operation"`
+	// Raw reports whether sys/raw is accessible
+	Raw    bool    `json:"raw"`
+	Checks []Check `json:"checks"`
+}
+
+// Missing returns checks of required paths the token lacks capabilities on
+func (p *PreflightReport) Missing() []Check {
+	return p.filter(false)
+}
+
+// Degraded returns checks of optional paths the token lacks capabilities on
+func (p *PreflightReport) Degraded() []Check {
+	return p.filter(true)
+}
+
+func (p *PreflightReport) filter(optional bool) []Check {
+	var checks []Check
+	for _, c := range p.Checks {
+		if len(c.Missing) > 0 && c.Optional == optional {
+			checks = append(checks, c)
+		}
+	}
+	return checks
+}
+
+// Err returns an error listing required paths the token lacks capabilities on, nil when the run can start
+func (p *PreflightReport) Err() error {
+	missing := p.Missing()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	var lines []string
+	for _, c := range missing {
+		lines = append(lines, fmt.Sprintf("%v: %v", path.Join(c.Namespace, c.Path), strings.Join(c.Missing, ", ")))
+	}
+	return fmt.Errorf("%v refused, the token lacks capabilities on %d paths:\n%v", p.Operation, len(missing), strings.Join(lines, "\n"))
+}
+
+// preflightName replaces globs of a path queried with sys/capabilities-self
+const preflightName = "hs-vault-preflight"
+
+// Preflight checks with sys/capabilities-self that the token has the capabilities every path of a backup
+// or restore of config needs, including sys/raw paths which only degrade engines when they are missing
+func Preflight(ctx context.Context, client *vault.Client, config Config, operation string) (*PreflightReport, error) {
+	client, err := namespaced(client, config.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PreflightReport{Operation: operation, Raw: RawAccessible(ctx, client)}
+	reqs, err := requirements(ctx, client, config, operation, true, config.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	byNamespace := map[string][]Requirement{}
+	var namespaces []string
+	for _, req := range reqs {
+		if _, ok := byNamespace[req.Namespace]; !ok {
+			namespaces = append(namespaces, req.Namespace)
+		}
+		byNamespace[req.Namespace] = append(byNamespace[req.Namespace], req)
+	}
+
+	for _, ns := range namespaces {
+		nc := client
+		if ns != config.Namespace {
+			if nc, err = NamespaceClient(client, ns); err != nil {
+				return nil, err
+			}
+		}
+
+		checks, err := checkCapabilities(ctx, nc, byNamespace[ns])
+		if err != nil {
+			return nil, err
+		}
+		report.Checks = append(report.Checks, checks...)
+	}
+	return report, nil
+}
+
+// preflight refuses the run when the token lacks required capabilities, engines which will be degraded
+// are logged
+func (r *runner) preflight(ctx context.Context, client *vault.Client, operation string) error {
+	if !r.Preflight {
+		return nil
+	}
+
+	report, err := Preflight(ctx, client, r.Config, operation)
+	if err != nil {
+		return fmt.Errorf("preflight: %w", err)
+	}
+	for _, c := range report.Degraded() {
+		r.logger.Warn("Engine will be degraded, the token lacks capabilities", zap.String("namespace", c.Namespace),
+			zap.String("engine", c.Engine), zap.String("path", c.Path), zap.Strings("missing", c.Missing))
+	}
+	return report.Err()
+}
+
+// checkCapabilities queries the capabilities of the token on requirements of the client namespace.
+// Globs are replaced by a name, listing is queried on the directory
+func checkCapabilities(ctx context.Context, client *vault.Client, reqs []Requirement) ([]Check, error) {
+	var paths []string
+	for _, req := range reqs {
+		for _, capability := range req.Capabilities {
+			if p := queryPath(req.Path, capability); !contains(paths, p) {
+				paths = append(paths, p)
+			}
+		}
+	}
+
+	resp, err := client.System.QueryTokenSelfCapabilities(ctx, schema.QueryTokenSelfCapabilitiesRequest{Paths: paths})
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil || resp.Data == nil {
+		return nil, fmt.Errorf("sys/capabilities-self returned no capabilities")
+	}
+
+	var checks []Check
+	for _, req := range reqs {
+		check := Check{Requirement: req}
+		for _, capability := range req.Capabilities {
+			granted := granted(resp.Data[queryPath(req.Path, capability)])
+			if !contains(granted, "root") && !contains(granted, capability) {
+				check.Missing = append(check.Missing, capability)
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+func queryPath(p, capability string) string {
+	if capability == "list" {
+		return strings.TrimSuffix(p, "*")
+	}
+	return strings.NewReplacer("*", preflightName, "+", preflightName).Replace(p)
+}
+
+func granted(value interface{}) []string {
+	values, _ := value.([]interface{})
+	var capabilities []string
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			capabilities = append(capabilities, s)
+		}
+	}
+	return capabilities
+}
@@ -0,0 +1,121 @@
+package hsvault
+
+import (
+	"errors"
+	"github.com/zduymz/hs-vault/backends"
+	"github.com/zduymz/hs-vault/metrics"
+	"sort"
+	"sync"
+	"time"
+)
+
+// parallel calls fn for every key with at most Concurrency calls at a time, it returns the first error.
+// With ContinueOnError, keys after a failure are still processed and the errors are joined
+func (r *runner) parallel(keys []string, fn func(key string) error) error {
+	n := r.Concurrency
+	if n < 1 {
+		n = 1
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	sem := make(chan struct{}, n)
+
+	for _, key := range keys {
+		mu.Lock()
+		failed := len(errs) > 0
+		mu.Unlock()
+		if failed && !r.ContinueOnError {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(key string) {
+			defer func() { <-sem; wg.Done() }()
+			if err := fn(key); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(key)
+	}
+
+	wg.Wait()
+	if !r.ContinueOnError && len(errs) > 0 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// keepGoing returns err, with ContinueOnError it is added to errs and nil is returned instead
+func (r *runner) keepGoing(errs *[]error, err error) error {
+	if err == nil || !r.ContinueOnError {
+		return err
+	}
+	*errs = append(*errs, err)
+	return nil
+}
+
+// engineError returns the failure of the engine, a partial one when only keys failed, nil when it succeeded
+func engineError(operation, namespace, key string, mount Mount, se backends.Engine, err error) error {
+	var keys []backends.KeyError
+	if kr, ok := se.(backends.KeyErrorReporter); ok {
+		keys = kr.KeyErrors()
+	}
+	if err == nil && len(keys) == 0 {
+		return nil
+	}
+
+	return &backends.EngineError{
+		Operation: operation,
+		Namespace: namespace,
+		Path:      key,
+		Type:      string(mount.EngineType()),
+		Err:       err,
+		Keys:      keys,
+	}
+}
+
+// start returns the result of an engine starting now, OnEngineStart is called with it
+func (r *runner) start(namespace, key string, mount Mount) metrics.EngineResult {
+	result := metrics.EngineResult{
+		Namespace: namespace,
+		Path:      key,
+		Type:      string(mount.EngineType()),
+		Start:     time.Now(),
+	}
+	if r.OnEngineStart != nil {
+		r.OnEngineStart(result)
+	}
+	return result
+}
+
+// done adds the result of the engine to the run and calls OnEngineDone with it, se is nil when
+// the engine could not be created
+func (r *runner) done(result metrics.EngineResult, se backends.Engine, mismatches int, err error) {
+	result.Duration = time.Since(result.Start).Seconds()
+	result.Mismatches = mismatches
+	if sr, ok := se.(backends.StatsReporter); ok {
+		stats := sr.Stats()
+		result.Keys, result.Bytes = stats.Keys, stats.Bytes
+	}
+	if kr, ok := se.(backends.KeyErrorReporter); ok {
+		for _, ke := range kr.KeyErrors() {
+			result.FailedKeys = append(result.FailedKeys, metrics.FailedKey{Key: ke.Key, Error: ke.Err.Error()})
+		}
+	}
+
+	result = r.run.AddEngine(result, err)
+	if r.OnEngineDone != nil {
+		r.OnEngineDone(result)
+	}
+}
+
+func sorted(keys []string) []string {
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// Local keeps runs in a directory
+type Local struct {
+	Dir string
+}
+
+func (l *Local) Store(_ context.Context, local string) error {
+	dest := filepath.Join(l.Dir, filepath.Base(local))
+	if filepath.Clean(local) == dest {
+		return nil
+	}
+	if err := os.MkdirAll(l.Dir, 0755); err != nil {
+		return err
+	}
+	return os.Rename(local, dest)
+}
+
+func (l *Local) List(_ context.Context) ([]string, error) {
+	entries, err := os.ReadDir(l.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	return runs(names), nil
+}
+
+func (l *Local) Remove(_ context.Context, name string) error {
+	return os.RemoveAll(filepath.Join(l.Dir, name))
+}
+
+func (l *Local) Fetch(_ context.Context, name string) (string, func(), error) {
+	return filepath.Join(l.Dir, name), func() {}, nil
+}
+
+func (l *Local) String() string {
+	return l.Dir
+}
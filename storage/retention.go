@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Retention keeps the last runs and, grandfather-father-son style, the last run of each
+// of the last days, ISO weeks and months. A run is kept when any rule keeps it
+type Retention struct {
+	Keep    int
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+// Enabled reports whether runs are pruned at all
+func (r Retention) Enabled() bool {
+	return r.Keep > 0 || r.Daily > 0 || r.Weekly > 0 || r.Monthly > 0
+}
+
+// Expired returns the runs no rule keeps, runs are sorted oldest first
+func (r Retention) Expired(runs []string) []string {
+	kept := map[string]bool{}
+	for i := len(runs) - 1; i >= 0 && i >= len(runs)-r.Keep; i-- {
+		kept[runs[i]] = true
+	}
+
+	keepPeriods(runs, r.Daily, kept, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepPeriods(runs, r.Weekly, kept, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	keepPeriods(runs, r.Monthly, kept, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+
+	var expired []string
+	for _, run := range runs {
+		if !kept[run] {
+			expired = append(expired, run)
+		}
+	}
+	return expired
+}
+
+// keepPeriods keeps the newest run of each of the last n periods having runs
+func keepPeriods(runs []string, n int, kept map[string]bool, period func(time.Time) string) {
+	seen := map[string]bool{}
+	for i := len(runs) - 1; i >= 0 && len(seen) < n; i-- {
+		t, ok := RunTime(runs[i])
+		if !ok {
+			continue
+		}
+		p := period(t)
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		kept[runs[i]] = true
+	}
+}
+
+// Prune removes the runs of the storage the retention does not keep, it returns the removed runs
+func Prune(ctx context.Context, s Storage, r Retention) ([]string, error) {
+	if !r.Enabled() {
+		return nil, nil
+	}
+
+	runs, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, run := range r.Expired(runs) {
+		if err := s.Remove(ctx, run); err != nil {
+			return removed, err
+		}
+		removed = append(removed, run)
+	}
+	return removed, nil
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// S3 keeps runs in a bucket of Amazon S3 or any S3 compatible storage, eg: MinIO or GCS interoperability.
+// Directory runs are stored as one object per file under <prefix>/<run>/, archives as <prefix>/<run>.
+// Credentials come from AWS_ACCESS_KEY_ID, MINIO_ACCESS_KEY, ~/.aws/credentials or the instance role
+type S3 struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3 parses s3://bucket/prefix?endpoint=host:port&region=name&insecure=true
+func NewS3(u *url.URL) (*S3, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("bucket is missing in '%v'", u.Redacted())
+	}
+
+	q := u.Query()
+	endpoint := q.Get("endpoint")
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{Client: &http.Client{Transport: http.DefaultTransport}},
+	})
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: q.Get("insecure") != "true",
+		Region: q.Get("region"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3{client: client, bucket: u.Host, prefix: strings.Trim(u.Path, "/")}, nil
+}
+
+func (s *S3) key(name string) string {
+	return path.Join(s.prefix, name)
+}
+
+// Store uploads the run and removes the local copy
+func (s *S3) Store(ctx context.Context, local string) error {
+	info, err := os.Stat(local)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(local)
+	if !info.IsDir() {
+		if _, err := s.client.FPutObject(ctx, s.bucket, s.key(name), local, minio.PutObjectOptions{}); err != nil {
+			return err
+		}
+		return os.Remove(local)
+	}
+
+	err = filepath.WalkDir(local, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(local, p)
+		if err != nil {
+			return err
+		}
+		_, err = s.client.FPutObject(ctx, s.bucket, s.key(path.Join(name, filepath.ToSlash(rel))), p, minio.PutObjectOptions{})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(local)
+}
+
+func (s *S3) List(ctx context.Context) ([]string, error) {
+	prefix := s.prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var names []string
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		names = append(names, strings.TrimSuffix(strings.TrimPrefix(obj.Key, prefix), "/"))
+	}
+	return runs(names), nil
+}
+
+// objects returns the keys of a run, one for archives, every file for directories
+func (s *S3) objects(ctx context.Context, name string) ([]string, error) {
+	if _, err := s.client.StatObject(ctx, s.bucket, s.key(name), minio.StatObjectOptions{}); err == nil {
+		return []string{s.key(name)}, nil
+	}
+
+	var keys []string
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.key(name) + "/", Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		keys = append(keys, obj.Key)
+	}
+	return keys, nil
+}
+
+func (s *S3) Remove(ctx context.Context, name string) error {
+	keys, err := s.objects(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fetch downloads the run into a temporary directory
+func (s *S3) Fetch(ctx context.Context, name string) (string, func(), error) {
+	keys, err := s.objects(ctx, name)
+	if err != nil {
+		return "", func() {}, err
+	}
+	if len(keys) == 0 {
+		return "", func() {}, fmt.Errorf("run '%v' not found in %v", name, s)
+	}
+
+	tmp, err := os.MkdirTemp("", "hs-vault-")
+	if err != nil {
+		return "", func() {}, err
+	}
+	cleanup := func() { _ = os.RemoveAll(tmp) }
+
+	for _, key := range keys {
+		rel := strings.TrimPrefix(key, s.prefix+"/")
+		if s.prefix == "" {
+			rel = key
+		}
+		f := filepath.Join(tmp, filepath.FromSlash(rel))
+		if !strings.HasPrefix(f, tmp+string(filepath.Separator)) {
+			cleanup()
+			return "", func() {}, fmt.Errorf("object '%v' is outside of the run", key)
+		}
+		if err := s.client.FGetObject(ctx, s.bucket, key, f, minio.GetObjectOptions{}); err != nil {
+			cleanup()
+			return "", func() {}, err
+		}
+	}
+	return filepath.Join(tmp, name), cleanup, nil
+}
+
+func (s *S3) String() string {
+	return "s3://" + path.Join(s.bucket, s.prefix)
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"github.com/zduymz/hs-vault/archive"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RunIDLayout names each run of a backup, eg: 20240102T030405Z
+const RunIDLayout = "20060102T150405Z"
+
+// Storage keeps backup runs, a run is a directory or an archive named by its run id
+type Storage interface {
+	// Store moves the run written at the local path, a directory or an archive, into the storage
+	Store(ctx context.Context, local string) error
+	// List returns the names of runs, oldest first
+	List(ctx context.Context) ([]string, error)
+	// Remove deletes a run
+	Remove(ctx context.Context, name string) error
+	// Fetch makes a run available locally, cleanup removes local copies
+	Fetch(ctx context.Context, name string) (local string, cleanup func(), err error)
+	String() string
+}
+
+// New returns the storage of a destination, s3://bucket/prefix for object storage,
+// a local directory otherwise
+func New(destination string) (Storage, error) {
+	if !IsRemote(destination) {
+		return &Local{Dir: destination}, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return nil, err
+	}
+	return NewS3(u)
+}
+
+// IsRemote reports whether the destination is an object storage URL
+func IsRemote(destination string) bool {
+	return strings.HasPrefix(destination, "s3://")
+}
+
+// NewRunID returns the run id of a run starting at t
+func NewRunID(t time.Time) string {
+	return t.UTC().Format(RunIDLayout)
+}
+
+// RunTime returns when the run started, false when name is not a run
+func RunTime(name string) (time.Time, bool) {
+	id := strings.TrimSuffix(strings.TrimSuffix(name, archive.EncryptedExtension), archive.Extension)
+	t, err := time.Parse(RunIDLayout, id)
+	return t, err == nil
+}
+
+// runs keeps run names, oldest first
+func runs(names []string) []string {
+	var runs []string
+	for _, name := range names {
+		if _, ok := RunTime(name); ok {
+			runs = append(runs, name)
+		}
+	}
+	sort.Strings(runs)
+	return runs
+}
+
+// Latest returns the last run of the storage, empty when there is none
+func Latest(ctx context.Context, s Storage) (string, error) {
+	names, err := s.List(ctx)
+	if err != nil || len(names) == 0 {
+		return "", err
+	}
+	return names[len(names)-1], nil
+}
@@ -0,0 +1,143 @@
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/zduymz/hs-vault/backends"
+	"go.uber.org/zap"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Audit backs up audit device definitions, one file per device path
+type Audit struct {
+	*backends.Object
+}
+
+type AuditDevice struct {
+	Type        string                 `json:"type"`
+	Description string                 `json:"description"`
+	Options     map[string]interface{} `json:"options"`
+	Local       bool                   `json:"local"`
+}
+
+// auditRewriteOptions are device options holding environment specific locations
+// auditPaths need sudo, audit devices are listed and enabled with it
+var auditPaths = backends.Paths{
+	Backup:  []backends.Access{{Capabilities: []string{"read", "sudo"}}},
+	Restore: []backends.Access{{Capabilities: []string{"read", "sudo"}}, {Path: "*", Capabilities: []string{"create", "update", "sudo"}}},
+}
+
+var auditRewriteOptions = []string{"file_path", "address"}
+
+func (s *Audit) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	l.Debug("List audit devices")
+	devices, err := s.Vault.System.AuditingListEnabledDevices(ctx)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range devices.Data {
+		device, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		l.Debug("Write audit device to local file", zap.String("path", key))
+		if err := s.WriteVaultResponse(ctx, strings.TrimSuffix(key, "/"), device); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Audit) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	l.Debug("List audit devices")
+	devices, err := s.Vault.System.AuditingListEnabledDevices(ctx)
+	if err != nil {
+		return err
+	}
+
+	paths, err := s.LocalWalk(ctx, s.Options.RestorePath, "")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, p := range paths {
+		if _, ok := devices.Data[p+"/"]; ok {
+			l.Info("Audit device is already enabled, skip restore", zap.String("path", p))
+			continue
+		}
+
+		data, err := s.ReadFileAndB64Decode(ctx, p)
+		if err != nil {
+			return err
+		}
+
+		var device AuditDevice
+		if err := json.Unmarshal(data, &device); err != nil {
+			return err
+		}
+
+		for _, option := range auditRewriteOptions {
+			value, ok := device.Options[option].(string)
+			if !ok {
+				continue
+			}
+			// the longest prefix wins, eg: /var/log/vault before /var/log
+			for _, from := range longestFirst(s.Options.AuditRewrites) {
+				if rewritten, ok := rewritePrefix(value, from, s.Options.AuditRewrites[from]); ok {
+					l.Info("Rewrite audit device option", zap.String("path", p), zap.String("option", option),
+						zap.String("from", value), zap.String("to", rewritten))
+					device.Options[option] = rewritten
+					break
+				}
+			}
+		}
+
+		vp := path.Join(s.Engine.Path, p)
+		l.Debug("Enable audit device", zap.String("path", vp))
+		if err := s.VaultWriteOnly(ctx, vp, map[string]interface{}{
+			"type":        device.Type,
+			"description": device.Description,
+			"options":     device.Options,
+			"local":       device.Local,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rewritePrefix replaces the prefix from of value by to, from matches whole path elements:
+// /var/log matches /var/log and /var/log/vault.log but not /var/logs
+func rewritePrefix(value, from, to string) (string, bool) {
+	from = strings.TrimSuffix(from, "/")
+	if value != from && !strings.HasPrefix(value, from+"/") {
+		return "", false
+	}
+	return strings.TrimSuffix(to, "/") + strings.TrimPrefix(value, from), true
+}
+
+// longestFirst returns the keys of mapping sorted longest first, keys of the same length by name
+func longestFirst(mapping map[string]string) []string {
+	keys := make([]string, 0, len(mapping))
+	for k := range mapping {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
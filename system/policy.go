@@ -0,0 +1,162 @@
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/zduymz/hs-vault/backends"
+	"go.uber.org/zap"
+	"os"
+	"path"
+	"strings"
+)
+
+// Policy backs up ACL, password, EGP and RGP policies as readable files:
+// acl/<name>.hcl, password/<name>.hcl, egp/<name>.sentinel and rgp/<name>.sentinel,
+// EGP and RGP settings are kept next to the policy in <name>.json
+type Policy struct {
+	*backends.Object
+}
+
+var policyPaths = backends.Paths{
+	Backup:  backends.Reads("acl/*", "password/*", "egp/*", "rgp/*"),
+	Restore: backends.Writes("acl/*", "password/*", "egp/*", "rgp/*"),
+}
+
+type SentinelPolicyConfig struct {
+	EnforcementLevel string   `json:"enforcement_level"`
+	Paths            []string `json:"paths,omitempty"`
+}
+
+func (s *Policy) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	for _, kind := range []string{"acl", "password"} {
+		l.Debug("Start backup policies", zap.String("kind", kind))
+		if err := s.backupPolicies(ctx, kind, ".hcl"); err != nil {
+			return err
+		}
+	}
+
+	// EGP and RGP are only available in Vault Enterprise, listing returns 404 otherwise
+	for _, kind := range []string{"egp", "rgp"} {
+		l.Debug("Start backup policies", zap.String("kind", kind))
+		if err := s.backupPolicies(ctx, kind, ".sentinel"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Policy) backupPolicies(ctx context.Context, kind, ext string) error {
+	l := s.L.With(zap.String("method", "backupPolicies"))
+
+	paths, err := s.VaultWalk(ctx, s.Engine.Path, kind)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		// root policy can not be read nor written
+		if kind == "acl" && path.Base(p) == "root" {
+			continue
+		}
+
+		vp := path.Join(s.Engine.Path, p)
+		l.Debug("Read policy", zap.String("path", vp))
+		data, err := s.Vault.Read(ctx, vp)
+		if err != nil {
+			return err
+		}
+
+		policy, _ := data.Data["policy"].(string)
+		if err := s.WriteData(ctx, p+ext, []byte(policy)); err != nil {
+			return err
+		}
+
+		if ext != ".sentinel" {
+			continue
+		}
+
+		var config SentinelPolicyConfig
+		config.EnforcementLevel, _ = data.Data["enforcement_level"].(string)
+		if paths, ok := data.Data["paths"].([]interface{}); ok {
+			for _, v := range paths {
+				config.Paths = append(config.Paths, v.(string))
+			}
+		}
+
+		content, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := s.WriteData(ctx, p+".json", content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Policy) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	for _, kind := range []string{"acl", "password", "egp", "rgp"} {
+		l.Debug("Start restore policies", zap.String("kind", kind))
+		if err := s.restorePolicies(ctx, kind); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Policy) restorePolicies(ctx context.Context, kind string) error {
+	l := s.L.With(zap.String("method", "restorePolicies"))
+
+	paths, err := s.LocalWalk(ctx, s.Options.RestorePath, kind)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, p := range paths {
+		ext := path.Ext(p)
+		if ext != ".hcl" && ext != ".sentinel" {
+			continue
+		}
+
+		policy, err := os.ReadFile(path.Join(s.Options.RestorePath, p))
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(p, ext)
+		payload := map[string]interface{}{
+			"policy": string(policy),
+		}
+
+		if ext == ".sentinel" {
+			content, err := os.ReadFile(path.Join(s.Options.RestorePath, name+".json"))
+			if err != nil {
+				return err
+			}
+
+			var config SentinelPolicyConfig
+			if err := json.Unmarshal(content, &config); err != nil {
+				return err
+			}
+			payload["enforcement_level"] = config.EnforcementLevel
+			if kind == "egp" {
+				payload["paths"] = config.Paths
+			}
+		}
+
+		vp := path.Join(s.Engine.Path, name)
+		l.Debug("Write policy to vault", zap.String("path", vp))
+		if err := s.VaultWrite(ctx, vp, payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
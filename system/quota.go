@@ -0,0 +1,119 @@
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/zduymz/hs-vault/backends"
+	"go.uber.org/zap"
+	"os"
+	"path"
+	"strings"
+)
+
+// Quota backs up sys/quotas/config with rate-limit and lease-count quotas,
+// lease-count quotas only exist in Vault Enterprise
+type Quota struct {
+	*backends.Object
+}
+
+var quotaPaths = backends.Paths{
+	Backup:  backends.Reads("config", "rate-limit/*", "lease-count/*"),
+	Restore: backends.Writes("config", "rate-limit/*", "lease-count/*"),
+}
+
+var quotaTypes = []string{"rate-limit", "lease-count"}
+
+func (s *Quota) Backup(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Backup"))
+
+	l.Debug("Start backup quotas configuration")
+	if err := s.VaultBackupSingleKey(ctx, "", "config"); err != nil {
+		return err
+	}
+
+	for _, qt := range quotaTypes {
+		l.Debug("Start backup quotas", zap.String("type", qt))
+		if err := s.VaultBackupRoles(ctx, qt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Quota) Restore(ctx context.Context) error {
+	l := s.L.With(zap.String("method", "Restore"))
+
+	l.Debug("Start restore quotas configuration")
+	data, err := s.ReadFileAndB64Decode(ctx, "config")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if os.IsNotExist(err) {
+		l.Warn("No quotas configuration found, skip restore quotas configuration")
+	} else {
+		payload := map[string]interface{}{}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+
+		if paths, ok := payload["rate_limit_exempt_paths"].([]interface{}); ok {
+			for i, p := range paths {
+				paths[i] = s.remapPath(p.(string))
+			}
+		}
+
+		l.Debug("Write quotas configuration to vault")
+		if err := s.VaultWrite(ctx, path.Join(s.Engine.Path, "config"), payload); err != nil {
+			return err
+		}
+	}
+
+	for _, qt := range quotaTypes {
+		l.Debug("Start restore quotas", zap.String("type", qt))
+		paths, err := s.LocalWalk(ctx, s.Options.RestorePath, qt)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		for _, p := range paths {
+			data, err := s.ReadFileAndB64Decode(ctx, p)
+			if err != nil {
+				return err
+			}
+
+			payload := map[string]interface{}{}
+			if err := json.Unmarshal(data, &payload); err != nil {
+				return err
+			}
+
+			if qp, ok := payload["path"].(string); ok {
+				payload["path"] = s.remapPath(qp)
+			}
+
+			vp := path.Join(s.Engine.Path, p)
+			l.Debug("Write quota to vault", zap.String("path", vp), zap.Any("quota-path", payload["path"]))
+			if err := s.VaultWrite(ctx, vp, payload); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// remapPath replaces the mount of a quota path according to Options.PathRemap,
+// eg: kv=kv-new turns kv/ into kv-new/ and kv/app into kv-new/app. Nested mounts are
+// matched first, kv/team=y turns kv/team/app into y/app even with kv=x
+func (s *Quota) remapPath(p string) string {
+	for _, key := range longestFirst(s.Options.PathRemap) {
+		from := strings.Trim(key, "/")
+		to := strings.Trim(s.Options.PathRemap[key], "/")
+		trimmed := strings.TrimPrefix(p, "/")
+		if trimmed == from || strings.HasPrefix(trimmed, from+"/") {
+			return to + strings.TrimPrefix(trimmed, from)
+		}
+	}
+	return p
+}
@@ -0,0 +1,54 @@
+package system
+
+import (
+	"github.com/hashicorp/vault-client-go"
+	"github.com/zduymz/hs-vault/backends"
+	"strings"
+)
+
+// ComponentType is a cluster level configuration which does not belong to any mount
+type ComponentType string
+
+const (
+	AuditComponent  ComponentType = "audit"
+	PolicyComponent ComponentType = "policy"
+	QuotaComponent  ComponentType = "quota"
+)
+
+// Components returns the API path of every system component available in the namespace,
+// audit devices only exist in the root namespace
+func Components(namespace string) map[string]ComponentType {
+	components := map[string]ComponentType{
+		"sys/policies": PolicyComponent,
+	}
+	if strings.Trim(namespace, "/") == "" {
+		components["sys/audit"] = AuditComponent
+		components["sys/quotas"] = QuotaComponent
+	}
+	return components
+}
+
+// RestoreAfterEngines reports whether the component refers to mounts and must be restored
+// once engines are restored
+func RestoreAfterEngines(ct ComponentType) bool {
+	return ct == QuotaComponent
+}
+
+// components are the system components implementations
+var components = backends.NewRegistry()
+
+// Lookup returns the implementation of the system component type
+func Lookup(ct ComponentType) (backends.Registration, bool) {
+	return components.Lookup(backends.EngineType(ct))
+}
+
+// NewComponent returns the engine for a system component located at e.Path, eg: sys/policies
+func NewComponent(v *vault.Client, e *backends.SecretEngine, options *backends.Options, ct ComponentType) (backends.Engine, error) {
+	return components.New(v, e, options, backends.EngineType(ct))
+}
+
+func init() {
+	components.Register(backends.EngineType(AuditComponent), func(o *backends.Object) backends.Engine { return &Audit{o} }, backends.Capabilities{Paths: auditPaths})
+	components.Register(backends.EngineType(PolicyComponent), func(o *backends.Object) backends.Engine { return &Policy{o} }, backends.Capabilities{Verify: true, Paths: policyPaths})
+	components.Register(backends.EngineType(QuotaComponent), func(o *backends.Object) backends.Engine { return &Quota{o} }, backends.Capabilities{Verify: true, Diff: true, Paths: quotaPaths})
+}
@@ -0,0 +1,91 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Export writes secrets as one JSON or YAML file keyed by secret path, or as one dotenv file per secret
+// under out, eg: out/app/db.env. JSON and YAML are printed when out is -.
+func Export(secrets Secrets, format Format, out string) error {
+	switch format {
+	case JSONFormat, YAMLFormat:
+		content, err := Marshal(secrets, format)
+		if err != nil {
+			return err
+		}
+		if out == "-" {
+			_, err := os.Stdout.Write(content)
+			return err
+		}
+		if err := os.MkdirAll(path.Dir(out), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(out, content, 0600)
+
+	case DotenvFormat:
+		// every secret is encoded first so that nothing is written when one can not be
+		files := map[string][]byte{}
+		for _, key := range secrets.Keys() {
+			content, err := MarshalDotenv(secrets[key])
+			if err != nil {
+				return fmt.Errorf("secret '%v': %w", key, err)
+			}
+			files[key] = content
+		}
+
+		for _, key := range secrets.Keys() {
+			of := path.Join(out, key+".env")
+			if err := os.MkdirAll(path.Dir(of), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(of, files[key], 0600); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown format '%v', expected json, yaml or dotenv", format)
+}
+
+// Marshal encodes secrets as JSON or YAML keyed by secret path
+func Marshal(secrets Secrets, format Format) ([]byte, error) {
+	switch format {
+	case JSONFormat:
+		content, err := json.MarshalIndent(secrets, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(content, '\n'), nil
+	case YAMLFormat:
+		return yaml.Marshal(map[string]map[string]interface{}(secrets))
+	}
+	return nil, fmt.Errorf("unknown format '%v', expected json or yaml", format)
+}
+
+// MarshalDotenv encodes one secret as KEY="value" lines, values which are not strings are refused
+// because dotenv files are read back as strings
+func MarshalDotenv(data map[string]interface{}) ([]byte, error) {
+	var keys []string
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		value, ok := data[k].(string)
+		if !ok {
+			return nil, fmt.Errorf("value of '%v' is a %T, dotenv only holds strings, export as json or yaml", k, data[k])
+		}
+		fmt.Fprintf(&b, "%v=%v\n", k, strconv.Quote(value))
+	}
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,220 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// KubernetesFormat reads Secret manifests, data is base64 decoded and stringData is taken as is
+	KubernetesFormat Format = "kubernetes"
+	// SOPSFormat reads a SOPS-decrypted YAML tree, every map holding values is a secret at its path
+	SOPSFormat Format = "sops"
+	// VaultExportFormat reads nested JSON where keys ending with / are folders, eg: {"app/": {"db": {...}}}
+	VaultExportFormat Format = "vault-export"
+)
+
+// ReadItems reads secrets of any supported format from a file, or a directory of files
+func ReadItems(source string, format Format) ([]Item, error) {
+	switch format {
+	case JSONFormat, YAMLFormat, DotenvFormat:
+		secrets, err := Read(source, format)
+		if err != nil {
+			return nil, err
+		}
+		return secretsItems(secrets, fileName(source)), nil
+	}
+
+	files, err := readFiles(source, ".yaml", ".yml", ".json")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for f := range files {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+
+	var items []Item
+	for _, f := range names {
+		var fileItems []Item
+		switch format {
+		case KubernetesFormat:
+			fileItems, err = kubernetesItems(files[f])
+		case SOPSFormat:
+			fileItems, err = sopsItems(files[f])
+		case VaultExportFormat:
+			fileItems, err = vaultExportItems(files[f])
+		default:
+			return nil, fmt.Errorf("unknown format '%v', expected json, yaml, dotenv, kubernetes, sops or vault-export", format)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", f, err)
+		}
+
+		// values at the root of a tree land at the file name
+		for i := range fileItems {
+			fileItems[i].File = fileName(f)
+			if fileItems[i].Key == "" {
+				fileItems[i].Key, fileItems[i].Name = fileItems[i].File, fileItems[i].File
+			}
+		}
+		items = append(items, fileItems...)
+	}
+	return items, nil
+}
+
+// ReadTemplate reads secrets of any supported format and places them with the path template
+func ReadTemplate(source string, format Format, pathTemplate string) (Secrets, error) {
+	items, err := ReadItems(source, format)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyTemplate(items, pathTemplate)
+}
+
+type kubernetesSecret struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string            `yaml:"name"`
+		Namespace string            `yaml:"namespace"`
+		Labels    map[string]string `yaml:"labels"`
+	} `yaml:"metadata"`
+	Type       string             `yaml:"type"`
+	Data       map[string]string  `yaml:"data"`
+	StringData map[string]string  `yaml:"stringData"`
+	Items      []kubernetesSecret `yaml:"items"`
+}
+
+// kubernetesItems reads Secrets of every document, List items included, other kinds are skipped
+func kubernetesItems(content []byte) ([]Item, error) {
+	var items []Item
+
+	d := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var secret kubernetesSecret
+		if err := d.Decode(&secret); err != nil {
+			if errors.Is(err, io.EOF) {
+				return items, nil
+			}
+			return nil, err
+		}
+
+		fromList, err := kubernetesSecretItems(secret)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, fromList...)
+	}
+}
+
+func kubernetesSecretItems(secret kubernetesSecret) ([]Item, error) {
+	if strings.HasSuffix(secret.Kind, "List") {
+		var items []Item
+		for _, s := range secret.Items {
+			fromList, err := kubernetesSecretItems(s)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, fromList...)
+		}
+		return items, nil
+	}
+
+	if secret.Kind != "Secret" {
+		return nil, nil
+	}
+
+	data := map[string]interface{}{}
+	for k, v := range secret.Data {
+		bs, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("secret '%v' key '%v': %w", secret.Metadata.Name, k, err)
+		}
+		data[k] = string(bs)
+	}
+	// stringData takes precedence like in the API server
+	for k, v := range secret.StringData {
+		data[k] = v
+	}
+
+	return []Item{{
+		Key:       path.Join(secret.Metadata.Namespace, secret.Metadata.Name),
+		Name:      secret.Metadata.Name,
+		Namespace: secret.Metadata.Namespace,
+		Type:      secret.Type,
+		Labels:    secret.Metadata.Labels,
+		Data:      data,
+	}}, nil
+}
+
+// sopsItems walks a decrypted tree, sops metadata is dropped
+func sopsItems(content []byte) ([]Item, error) {
+	tree := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &tree); err != nil {
+		return nil, err
+	}
+	delete(tree, "sops")
+
+	var items []Item
+	walkTree("", tree, &items)
+	return items, nil
+}
+
+// walkTree adds values of a map as a secret at prefix and walks maps below it
+func walkTree(prefix string, tree map[string]interface{}, items *[]Item) {
+	data := map[string]interface{}{}
+	for k, v := range tree {
+		if m, ok := v.(map[string]interface{}); ok {
+			walkTree(path.Join(prefix, k), m, items)
+			continue
+		}
+		data[k] = v
+	}
+
+	if len(data) > 0 {
+		*items = append(*items, Item{Key: prefix, Name: path.Base(prefix), Data: data})
+	}
+}
+
+// vaultExportItems walks folders, keys ending with /, and takes other keys as secrets
+func vaultExportItems(content []byte) ([]Item, error) {
+	tree := map[string]interface{}{}
+	if err := json.Unmarshal(content, &tree); err != nil {
+		return nil, err
+	}
+
+	var items []Item
+	if err := walkFolders("", tree, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func walkFolders(prefix string, tree map[string]interface{}, items *[]Item) error {
+	for k, v := range tree {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("'%v' is neither a folder nor a secret", path.Join(prefix, k))
+		}
+
+		key := path.Join(prefix, k)
+		if strings.HasSuffix(k, "/") {
+			if err := walkFolders(key, m, items); err != nil {
+				return err
+			}
+			continue
+		}
+		*items = append(*items, Item{Key: key, Name: path.Base(key), Data: m})
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Read reads secrets written by Export, a dotenv source is a directory of <key>.env files or a single one
+func Read(source string, format Format) (Secrets, error) {
+	switch format {
+	case JSONFormat, YAMLFormat:
+		content, err := os.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		return Unmarshal(content, format)
+
+	case DotenvFormat:
+		return readDotenv(source)
+	}
+
+	return nil, fmt.Errorf("unknown format '%v', expected json, yaml or dotenv", format)
+}
+
+// Unmarshal decodes secrets encoded by Marshal
+func Unmarshal(content []byte, format Format) (Secrets, error) {
+	secrets := Secrets{}
+	switch format {
+	case JSONFormat:
+		if err := json.Unmarshal(content, &secrets); err != nil {
+			return nil, err
+		}
+	case YAMLFormat:
+		if err := yaml.Unmarshal(content, &secrets); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format '%v', expected json or yaml", format)
+	}
+	return secrets, nil
+}
+
+func readDotenv(source string) (Secrets, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := Secrets{}
+	if !info.IsDir() {
+		data, err := readDotenvFile(source)
+		if err != nil {
+			return nil, err
+		}
+		secrets[strings.TrimSuffix(filepath.Base(source), ".env")] = data
+		return secrets, nil
+	}
+
+	err = filepath.WalkDir(source, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(p) != ".env" {
+			return err
+		}
+
+		rel, err := filepath.Rel(source, p)
+		if err != nil {
+			return err
+		}
+
+		data, err := readDotenvFile(p)
+		if err != nil {
+			return err
+		}
+		secrets[strings.TrimSuffix(filepath.ToSlash(rel), ".env")] = data
+		return nil
+	})
+	return secrets, err
+}
+
+func readDotenvFile(f string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalDotenv(content)
+}
+
+// UnmarshalDotenv decodes KEY=value lines, quoted values are unquoted, comments and blank lines are skipped
+func UnmarshalDotenv(content []byte) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=value", n)
+		}
+
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, `"`):
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			value = unquoted
+		case strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") && len(value) > 1:
+			value = value[1 : len(value)-1]
+		}
+		data[strings.TrimSpace(key)] = value
+	}
+	return data, scanner.Err()
+}
@@ -0,0 +1,121 @@
+package transfer
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Item is one secret read from a source with the variables available to path templates
+type Item struct {
+	// Key is where the secret lands by default, eg: app/db or <namespace>/<name> for Kubernetes Secrets
+	Key string
+	// Name is the last element of Key, or the Kubernetes Secret name
+	Name string
+	// Namespace of a Kubernetes Secret
+	Namespace string
+	// Type of a Kubernetes Secret, eg: Opaque
+	Type string
+	// Labels of a Kubernetes Secret
+	Labels map[string]string
+	// File is the source file name without extension
+	File string
+	Data map[string]interface{}
+}
+
+// DefaultPathTemplate keeps the key of every item
+const DefaultPathTemplate = "{{.Key}}"
+
+var templateFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+}
+
+// ApplyTemplate returns items keyed by the path template executed with each item,
+// eg: k8s/{{.Namespace}}/{{.Name}}
+func ApplyTemplate(items []Item, pathTemplate string) (Secrets, error) {
+	if pathTemplate == "" {
+		pathTemplate = DefaultPathTemplate
+	}
+
+	t, err := template.New("path").Funcs(templateFuncs).Option("missingkey=error").Parse(pathTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := Secrets{}
+	for _, item := range items {
+		var b bytes.Buffer
+		if err := t.Execute(&b, item); err != nil {
+			return nil, err
+		}
+
+		key := strings.Trim(path.Clean("/"+b.String()), "/")
+		if key == "" {
+			return nil, fmt.Errorf("path template gives an empty key for '%v'", item.Key)
+		}
+		if _, ok := secrets[key]; ok {
+			return nil, fmt.Errorf("path template gives the same key '%v' to several secrets", key)
+		}
+		secrets[key] = item.Data
+	}
+	return secrets, nil
+}
+
+// secretsItems returns items of secrets keyed by path
+func secretsItems(secrets Secrets, file string) []Item {
+	var items []Item
+	for _, key := range secrets.Keys() {
+		items = append(items, Item{Key: key, Name: path.Base(key), File: file, Data: secrets[key]})
+	}
+	return items
+}
+
+// readFiles reads source, or every file of source with one of the extensions when it is a directory
+func readFiles(source string, extensions ...string) (map[string][]byte, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	if !info.IsDir() {
+		content, err := os.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		files[source] = content
+		return files, nil
+	}
+
+	err = filepath.WalkDir(source, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		for _, ext := range extensions {
+			if filepath.Ext(p) == ext {
+				content, err := os.ReadFile(p)
+				if err != nil {
+					return err
+				}
+				files[p] = content
+				break
+			}
+		}
+		return nil
+	})
+	return files, err
+}
+
+// fileName returns the file name without extension
+func fileName(f string) string {
+	return strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+}
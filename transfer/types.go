@@ -0,0 +1,99 @@
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/zduymz/hs-vault/backends"
+	"path"
+	"sort"
+	"strings"
+)
+
+type Format string
+
+const (
+	JSONFormat   Format = "json"
+	YAMLFormat   Format = "yaml"
+	DotenvFormat Format = "dotenv"
+)
+
+// Secrets maps kv keys, eg: app/db, to their data
+type Secrets map[string]map[string]interface{}
+
+// Keys returns keys in order
+func (s Secrets) Keys() []string {
+	var keys []string
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Filter returns secrets whose key matches one of the glob patterns, eg: app/*,
+// or is below one of them, eg: app. Every secret is returned when there is no pattern.
+func (s Secrets) Filter(patterns []string) Secrets {
+	if len(patterns) == 0 {
+		return s
+	}
+
+	output := Secrets{}
+	for key, data := range s {
+		for _, pattern := range patterns {
+			matched, _ := path.Match(pattern, key)
+			if matched || strings.HasPrefix(key, strings.TrimSuffix(pattern, "/")+"/") {
+				output[key] = data
+				break
+			}
+		}
+	}
+	return output
+}
+
+// ReadBackup returns secrets of a kv v1 or v2 engine backup directory,
+// kv v2 secrets are read at their current version, deleted and destroyed ones are skipped
+func ReadBackup(dir string, engineType backends.EngineType) (Secrets, error) {
+	chunks, err := backends.ReadChunks(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := Secrets{}
+	for key, content := range chunks {
+		switch engineType {
+		case backends.SecretV1Engine:
+			var data map[string]interface{}
+			if err := json.Unmarshal(content, &data); err != nil {
+				return nil, err
+			}
+			secrets[key] = data
+
+		case backends.SecretV2Engine:
+			var backup backends.SecretV2Backup
+			if err := json.Unmarshal(content, &backup); err != nil {
+				return nil, err
+			}
+
+			version := backup.MetaData.CurrentVersion
+			if meta, ok := backup.MetaData.Versions[version]; !ok || meta.Destroyed || meta.DeletionTime != "" {
+				continue
+			}
+			secrets[key] = backup.Data[version]
+
+		default:
+			return nil, fmt.Errorf("engine type '%v' is not a kv engine", engineType)
+		}
+	}
+	return secrets, nil
+}
+
+// Import writes secrets in order through the kv engine writer used by restore
+func Import(ctx context.Context, w backends.SecretWriter, secrets Secrets) error {
+	for _, key := range secrets.Keys() {
+		if err := w.WriteSecret(ctx, key, secrets[key]); err != nil {
+			return fmt.Errorf("write '%v': %w", key, err)
+		}
+	}
+	return nil
+}